@@ -0,0 +1,410 @@
+package components
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authLog 管理员认证日志
+var authLog = utils.Logger("auth")
+
+// SessionCookieName 管理员会话 Cookie 名称
+const SessionCookieName = "vastvideo_session"
+
+// adminSession 管理员会话
+type adminSession struct {
+	expiresAt   time.Time
+	adultFilter bool
+}
+
+// AuthManager 管理员认证管理器
+type AuthManager struct {
+	passwordHash       []byte
+	sessionTTL         time.Duration
+	cookieSecure       bool
+	defaultAdultFilter bool
+
+	mu       sync.Mutex
+	sessions map[string]*adminSession
+}
+
+// NewAuthManager 根据配置创建认证管理器
+func NewAuthManager(config *utils.Config) (*AuthManager, error) {
+	ttl := time.Duration(config.Auth.SessionTTLHours) * time.Hour
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	am := &AuthManager{
+		sessionTTL:         ttl,
+		cookieSecure:       config.Auth.CookieSecure,
+		defaultAdultFilter: config.Filter.DefaultAdultFilter,
+		sessions:           make(map[string]*adminSession),
+	}
+
+	passwordHash, source, err := loadPasswordHash(config)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case passwordHash != "":
+		hash := []byte(passwordHash)
+		if _, err := bcrypt.Cost(hash); err != nil {
+			return nil, fmt.Errorf("无效的管理员密码哈希 (%s): %v", source, err)
+		}
+		am.passwordHash = hash
+	case config.Filter.AdminPassword != "":
+		// 兼容旧配置：明文密码仅在内存中哈希，不再对外暴露
+		authLog.Warn("⚠️ [filter] admin_password 为明文密码，请使用 -hash-password 生成哈希并写入 [auth] password_hash_file")
+		hash, err := bcrypt.GenerateFromPassword([]byte(config.Filter.AdminPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("哈希管理员密码失败: %v", err)
+		}
+		am.passwordHash = hash
+	default:
+		authLog.Warn("⚠️ 未配置管理员密码，管理接口将不可用")
+	}
+
+	return am, nil
+}
+
+// PasswordHashEnv 管理员密码哈希环境变量，优先于配置文件
+const PasswordHashEnv = "VASTVIDEO_ADMIN_PASSWORD_HASH"
+
+// loadPasswordHash 按优先级读取管理员密码哈希：环境变量 > [auth] password_hash_file 指定的文件 > [auth] password_hash
+// 返回哈希及其来源，都未配置时返回空字符串
+func loadPasswordHash(config *utils.Config) (string, string, error) {
+	if hash := strings.TrimSpace(os.Getenv(PasswordHashEnv)); hash != "" {
+		return hash, "环境变量 " + PasswordHashEnv, nil
+	}
+	if file := config.Auth.PasswordHashFile; file != "" {
+		data, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			if hash := strings.TrimSpace(string(data)); hash != "" {
+				return hash, file, nil
+			}
+		case !os.IsNotExist(err):
+			return "", "", fmt.Errorf("读取管理员密码哈希文件失败: %v", err)
+		}
+	}
+	return strings.TrimSpace(config.Auth.PasswordHash), "[auth] password_hash", nil
+}
+
+// HashPassword 生成 bcrypt 密码哈希，用于写入配置文件
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword 校验管理员密码
+func (am *AuthManager) VerifyPassword(password string) bool {
+	if len(am.passwordHash) == 0 || password == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(am.passwordHash, []byte(password)) == nil
+}
+
+// newSession 创建新的会话并返回令牌
+func (am *AuthManager) newSession() (string, *adminSession, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(buf)
+	session := &adminSession{
+		expiresAt:   time.Now().Add(am.sessionTTL),
+		adultFilter: am.defaultAdultFilter,
+	}
+
+	am.mu.Lock()
+	am.sessions[token] = session
+	am.mu.Unlock()
+	return token, session, nil
+}
+
+// requestToken 从 Cookie 或 Authorization: Bearer 头部获取会话令牌
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:])
+		}
+	}
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// session 获取请求对应的有效会话，不存在或已过期时返回 nil
+func (am *AuthManager) session(r *http.Request) *adminSession {
+	token := requestToken(r)
+	if token == "" {
+		return nil
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	s, ok := am.sessions[token]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(s.expiresAt) {
+		delete(am.sessions, token)
+		return nil
+	}
+	return s
+}
+
+// IsAuthenticated 判断请求是否已通过管理员认证
+func (am *AuthManager) IsAuthenticated(r *http.Request) bool {
+	return am.session(r) != nil
+}
+
+// AdultFilterEnabled 返回请求当前生效的成人内容过滤状态，未登录时始终过滤
+func (am *AuthManager) AdultFilterEnabled(r *http.Request) bool {
+	s := am.session(r)
+	if s == nil {
+		return true
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return s.adultFilter
+}
+
+// StartCleanup 启动过期会话的定期清理，ctx 取消时停止
+func (am *AuthManager) StartCleanup(ctx context.Context) {
+	go am.cleanupLoop(ctx, 10*time.Minute)
+}
+
+// cleanupLoop 按 interval 清理过期会话，ctx 取消时退出
+func (am *AuthManager) cleanupLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+		am.mu.Lock()
+		for token, s := range am.sessions {
+			if now.After(s.expiresAt) {
+				delete(am.sessions, token)
+			}
+		}
+		am.mu.Unlock()
+	}
+}
+
+// RequireAdmin 管理员认证中间件，未认证时返回 401
+func (am *AuthManager) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next(w, r)
+			return
+		}
+		if !am.IsAuthenticated(r) {
+			authLog.WarnContext(r.Context(), "🔒 未认证的管理请求", "path", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Unauthorized",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// HandleLoginAPI 处理 /api/auth/login 接口
+func (am *AuthManager) HandleLoginAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid request body",
+		})
+		return
+	}
+
+	if !am.VerifyPassword(req.Password) {
+		authLog.WarnContext(r.Context(), "🔒 管理员登录失败")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Invalid password",
+		})
+		return
+	}
+
+	token, session, err := am.newSession()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.expiresAt,
+		MaxAge:   int(am.sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   am.cookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"token":      token,
+			"expires_at": session.expiresAt.Unix(),
+		},
+	})
+	authLog.InfoContext(r.Context(), "🔓 管理员登录成功")
+}
+
+// HandleLogoutAPI 处理 /api/auth/logout 接口
+func (am *AuthManager) HandleLogoutAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if token := requestToken(r); token != "" {
+		am.mu.Lock()
+		delete(am.sessions, token)
+		am.mu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   am.cookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleAuthStatusAPI 处理 /api/auth/status 接口
+func (am *AuthManager) HandleAuthStatusAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := map[string]interface{}{
+		"authenticated": false,
+	}
+	if s := am.session(r); s != nil {
+		data["authenticated"] = true
+		data["expires_at"] = s.expiresAt.Unix()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// HandleFilterConfigAPI 处理 /api/filter_config 接口
+// GET 返回当前生效的过滤设置，POST 修改成人内容过滤开关（需要管理员会话）
+func (am *AuthManager) HandleFilterConfigAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		session := am.session(r)
+		adultFilter := am.defaultAdultFilter
+		if session != nil {
+			am.mu.Lock()
+			adultFilter = session.adultFilter
+			am.mu.Unlock()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"default_adult_filter": am.defaultAdultFilter,
+				"adult_filter":         adultFilter,
+				"authenticated":        session != nil,
+			},
+		})
+		authLog.DebugContext(r.Context(), "✅ /api/filter_config 请求")
+	case "POST":
+		am.RequireAdmin(am.updateFilterConfig)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateFilterConfig 修改当前会话的成人内容过滤开关
+func (am *AuthManager) updateFilterConfig(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AdultFilter *bool `json:"adult_filter"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil || req.AdultFilter == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Missing adult_filter field",
+		})
+		return
+	}
+
+	session := am.session(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	am.mu.Lock()
+	session.adultFilter = *req.AdultFilter
+	am.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"adult_filter": *req.AdultFilter,
+		},
+	})
+	authLog.InfoContext(r.Context(), "🔧 成人内容过滤已修改", "adult_filter", *req.AdultFilter)
+}
@@ -0,0 +1,314 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vastproxy-go/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPasswordHash 生成测试用的低成本密码哈希
+func testPasswordHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func newTestAuthManager(t *testing.T, cookieSecure bool) *AuthManager {
+	t.Helper()
+	config := &utils.Config{}
+	config.Auth.PasswordHash = testPasswordHash(t, "secret")
+	config.Auth.CookieSecure = cookieSecure
+	config.Filter.DefaultAdultFilter = true
+	am, err := NewAuthManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return am
+}
+
+// login 登录并返回响应
+func login(am *AuthManager, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"password": password})
+	w := httptest.NewRecorder()
+	am.HandleLoginAPI(w, httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(string(body))))
+	return w
+}
+
+func TestAuthLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		cookieSecure bool
+		body         string
+		wantStatus   int
+	}{
+		{"密码正确", false, `{"password":"secret"}`, http.StatusOK},
+		{"密码正确且启用 Secure", true, `{"password":"secret"}`, http.StatusOK},
+		{"密码错误", false, `{"password":"wrong"}`, http.StatusUnauthorized},
+		{"密码为空", false, `{"password":""}`, http.StatusUnauthorized},
+		{"请求体无效", false, `not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := newTestAuthManager(t, tt.cookieSecure)
+			w := httptest.NewRecorder()
+			am.HandleLoginAPI(w, httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			cookies := w.Result().Cookies()
+			if tt.wantStatus != http.StatusOK {
+				if len(cookies) != 0 {
+					t.Errorf("登录失败时不应设置 Cookie")
+				}
+				return
+			}
+			if len(cookies) != 1 || cookies[0].Name != SessionCookieName {
+				t.Fatalf("cookies = %v", cookies)
+			}
+			c := cookies[0]
+			if !c.HttpOnly || c.Secure != tt.cookieSecure || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+				t.Errorf("cookie = %+v", c)
+			}
+			var resp struct {
+				Data struct {
+					Token string `json:"token"`
+				} `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Data.Token != c.Value || len(c.Value) != 64 {
+				t.Errorf("token = %q, cookie = %q", resp.Data.Token, c.Value)
+			}
+		})
+	}
+
+	am := newTestAuthManager(t, false)
+	w := httptest.NewRecorder()
+	am.HandleLoginAPI(w, httptest.NewRequest("GET", "/api/auth/login", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAuthRequireAdmin(t *testing.T) {
+	am := newTestAuthManager(t, false)
+	token := login(am, "secret").Result().Cookies()[0].Value
+
+	expired, _, err := am.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.sessions[expired].expiresAt = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		cookie string
+		want   int
+	}{
+		{"未认证", "GET", nil, "", http.StatusUnauthorized},
+		{"会话 Cookie", "GET", nil, token, http.StatusOK},
+		{"Bearer 令牌", "GET", map[string]string{"Authorization": "Bearer " + token}, "", http.StatusOK},
+		{"Bearer 前缀不区分大小写", "GET", map[string]string{"Authorization": "bearer " + token}, "", http.StatusOK},
+		{"其他认证方式", "GET", map[string]string{"Authorization": "Basic " + token}, "", http.StatusUnauthorized},
+		{"无效令牌", "GET", map[string]string{"Authorization": "Bearer invalid"}, "", http.StatusUnauthorized},
+		{"过期会话", "GET", nil, expired, http.StatusUnauthorized},
+		{"预检请求放行", "OPTIONS", nil, "", http.StatusOK},
+	}
+	h := am.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/api/admin", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: tt.cookie})
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+	if _, ok := am.sessions[expired]; ok {
+		t.Error("过期会话应被删除")
+	}
+}
+
+func TestAuthCleanupLoop(t *testing.T) {
+	am := newTestAuthManager(t, false)
+	active := login(am, "secret").Result().Cookies()[0].Value
+	expired, _, err := am.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.mu.Lock()
+	am.sessions[expired].expiresAt = time.Now().Add(-time.Second)
+	am.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		am.cleanupLoop(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		am.mu.Lock()
+		_, ok := am.sessions[expired]
+		am.mu.Unlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("过期会话未被清理")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	am.mu.Lock()
+	_, ok := am.sessions[active]
+	am.mu.Unlock()
+	if !ok {
+		t.Error("未过期的会话不应被清理")
+	}
+
+	// ctx 取消后清理循环退出
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("ctx 取消后 cleanupLoop 未退出")
+	}
+}
+
+func TestAuthLogout(t *testing.T) {
+	am := newTestAuthManager(t, true)
+	token := login(am, "secret").Result().Cookies()[0].Value
+
+	r := httptest.NewRequest("POST", "/api/auth/logout", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	am.HandleLogoutAPI(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 || !c[0].Secure {
+		t.Errorf("注销后的 Cookie = %v", c)
+	}
+	if am.IsAuthenticated(r) {
+		t.Error("注销后会话应失效")
+	}
+}
+
+func TestAuthFilterConfig(t *testing.T) {
+	am := newTestAuthManager(t, false)
+	token := login(am, "secret").Result().Cookies()[0].Value
+
+	request := func(method, body string, authed bool) *http.Request {
+		r := httptest.NewRequest(method, "/api/filter_config", strings.NewReader(body))
+		if authed {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+	// adultFilter 通过 GET 接口读取请求生效的过滤状态
+	adultFilter := func(authed bool) bool {
+		w := httptest.NewRecorder()
+		am.HandleFilterConfigAPI(w, request("GET", "", authed))
+		var resp struct {
+			Data struct {
+				AdultFilter bool `json:"adult_filter"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Data.AdultFilter
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		authed     bool
+		wantStatus int
+		wantFilter bool // 之后已登录请求生效的过滤状态
+	}{
+		{"未登录不能修改", "POST", `{"adult_filter":false}`, false, http.StatusUnauthorized, true},
+		{"缺少字段", "POST", `{}`, true, http.StatusBadRequest, true},
+		{"关闭过滤", "POST", `{"adult_filter":false}`, true, http.StatusOK, false},
+		{"读取配置", "GET", "", true, http.StatusOK, false},
+		{"重新开启过滤", "POST", `{"adult_filter":true}`, true, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		am.HandleFilterConfigAPI(w, request(tt.method, tt.body, tt.authed))
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if got := adultFilter(true); got != tt.wantFilter {
+			t.Errorf("%s: 已登录请求过滤状态 = %v, want %v", tt.name, got, tt.wantFilter)
+		}
+		if !adultFilter(false) {
+			t.Errorf("%s: 未登录请求应始终过滤", tt.name)
+		}
+	}
+}
+
+func TestLoadPasswordHash(t *testing.T) {
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "admin.hash")
+	if err := os.WriteFile(hashFile, []byte("file-hash\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		env    string
+		file   string
+		config string
+		want   string
+	}{
+		{"环境变量优先", "env-hash", hashFile, "config-hash", "env-hash"},
+		{"其次为哈希文件", "", hashFile, "config-hash", "file-hash"},
+		{"哈希文件不存在时使用配置", "", filepath.Join(dir, "missing"), "config-hash", "config-hash"},
+		{"均未配置", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PasswordHashEnv, tt.env)
+			config := &utils.Config{}
+			config.Auth.PasswordHashFile = tt.file
+			config.Auth.PasswordHash = tt.config
+			got, _, err := loadPasswordHash(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("loadPasswordHash() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthManagerInvalidHash(t *testing.T) {
+	config := &utils.Config{}
+	config.Auth.PasswordHash = "not-a-bcrypt-hash"
+	if _, err := NewAuthManager(config); err == nil {
+		t.Error("无效的密码哈希应返回错误")
+	}
+}
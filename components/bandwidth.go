@@ -0,0 +1,294 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+
+	"golang.org/x/time/rate"
+)
+
+// 客户端空闲多久后从统计中移除
+const bandwidthClientIdleTTL = 10 * time.Minute
+
+// BandwidthLimiter 代理带宽限速器（全局 + 每客户端令牌桶）
+type BandwidthLimiter struct {
+	enabled     bool
+	globalKBps  int
+	clientKBps  int
+	burst       int
+	global      *rate.Limiter
+	clientLimit rate.Limit
+	exempt      []*net.IPNet
+
+	mu      sync.Mutex
+	clients map[string]*clientBandwidth
+}
+
+// clientBandwidth 单个客户端的限速器与流量统计
+type clientBandwidth struct {
+	limiter     *rate.Limiter
+	exempt      bool
+	active      int
+	totalBytes  int64
+	windowBytes int64
+	bytesPerSec float64
+	lastSeen    time.Time
+}
+
+// ClientBandwidthStats 客户端带宽统计
+type ClientBandwidthStats struct {
+	IP            string  `json:"ip"`
+	ActiveStreams int     `json:"active_streams"`
+	RateKBps      float64 `json:"rate_kbps"`
+	TotalBytes    int64   `json:"total_bytes"`
+	Exempt        bool    `json:"exempt"`
+	LastSeen      int64   `json:"last_seen"`
+}
+
+// NewBandwidthLimiter 根据配置创建带宽限速器
+func NewBandwidthLimiter(config *utils.Config) (*BandwidthLimiter, error) {
+	cfg := config.Bandwidth
+
+	burstKB := cfg.BurstKB
+	if burstKB <= 0 {
+		burstKB = 256
+	}
+
+	bl := &BandwidthLimiter{
+		enabled:     cfg.Enabled,
+		globalKBps:  cfg.GlobalLimitKBps,
+		clientKBps:  cfg.ClientLimitKBps,
+		burst:       burstKB * 1024,
+		clientLimit: kbpsToLimit(cfg.ClientLimitKBps),
+		clients:     make(map[string]*clientBandwidth),
+	}
+	bl.global = rate.NewLimiter(kbpsToLimit(cfg.GlobalLimitKBps), bl.burst)
+
+	exempt, err := utils.ParseCIDRList(cfg.Exempt)
+	if err != nil {
+		return nil, fmt.Errorf("解析带宽豁免列表失败: %v", err)
+	}
+	bl.exempt = exempt
+	return bl, nil
+}
+
+// Start 启动后台吞吐量采样，ctx 取消时停止
+func (bl *BandwidthLimiter) Start(ctx context.Context) {
+	go bl.sampleLoop(ctx, time.Second)
+}
+
+// kbpsToLimit 将 KB/s 转换为令牌桶速率，0 或负数表示不限制
+func kbpsToLimit(kbps int) rate.Limit {
+	if kbps <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(kbps * 1024)
+}
+
+// isExempt 判断客户端IP是否在豁免列表中
+func (bl *BandwidthLimiter) isExempt(clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, n := range bl.exempt {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire 登记一个客户端流，返回其统计对象
+// clientIP 为空（无法确定客户端IP）时该流单独限速且不计入客户端统计，避免所有客户端共用一个限速器
+func (bl *BandwidthLimiter) acquire(clientIP string) *clientBandwidth {
+	if clientIP == "" {
+		return &clientBandwidth{limiter: rate.NewLimiter(bl.clientLimit, bl.burst), active: 1}
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	c, ok := bl.clients[clientIP]
+	if !ok {
+		c = &clientBandwidth{
+			limiter: rate.NewLimiter(bl.clientLimit, bl.burst),
+			exempt:  bl.isExempt(clientIP),
+		}
+		bl.clients[clientIP] = c
+	}
+	c.active++
+	c.lastSeen = time.Now()
+	return c
+}
+
+// release 注销一个客户端流
+func (bl *BandwidthLimiter) release(c *clientBandwidth) {
+	bl.mu.Lock()
+	c.active--
+	c.lastSeen = time.Now()
+	bl.mu.Unlock()
+}
+
+// record 记录已发送的字节数
+func (bl *BandwidthLimiter) record(c *clientBandwidth, n int) {
+	bl.mu.Lock()
+	c.totalBytes += int64(n)
+	c.windowBytes += int64(n)
+	c.lastSeen = time.Now()
+	bl.mu.Unlock()
+}
+
+// sampleLoop 按 interval 采样各客户端吞吐量，并清理长时间空闲的客户端，ctx 取消时退出
+func (bl *BandwidthLimiter) sampleLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+		elapsed := now.Sub(last).Seconds()
+		last = now
+
+		bl.mu.Lock()
+		for ip, c := range bl.clients {
+			if elapsed > 0 {
+				c.bytesPerSec = float64(c.windowBytes) / elapsed
+			}
+			c.windowBytes = 0
+			if c.active == 0 && now.Sub(c.lastSeen) > bandwidthClientIdleTTL {
+				delete(bl.clients, ip)
+			}
+		}
+		bl.mu.Unlock()
+	}
+}
+
+// Wrap 为客户端包装一个限速写入器，调用方需在传输结束后调用返回的 release 函数；clientIP 为空表示无法确定客户端IP
+func (bl *BandwidthLimiter) Wrap(ctx context.Context, w io.Writer, clientIP string) (io.Writer, func()) {
+	c := bl.acquire(clientIP)
+	tw := &throttledWriter{
+		ctx:    ctx,
+		w:      w,
+		bl:     bl,
+		client: c,
+	}
+	return tw, func() { bl.release(c) }
+}
+
+// throttledWriter 按令牌桶速率分块写入
+type throttledWriter struct {
+	ctx    context.Context
+	w      io.Writer
+	bl     *BandwidthLimiter
+	client *clientBandwidth
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > tw.bl.burst {
+			n = tw.bl.burst
+		}
+
+		if tw.bl.enabled && !tw.client.exempt {
+			if err := tw.client.limiter.WaitN(tw.ctx, n); err != nil {
+				return written, err
+			}
+			if err := tw.bl.global.WaitN(tw.ctx, n); err != nil {
+				return written, err
+			}
+		}
+
+		m, err := tw.w.Write(p[:n])
+		written += m
+		tw.bl.record(tw.client, m)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Stats 返回所有客户端的带宽统计，按当前速率降序排列
+func (bl *BandwidthLimiter) Stats() []ClientBandwidthStats {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	stats := make([]ClientBandwidthStats, 0, len(bl.clients))
+	for ip, c := range bl.clients {
+		stats = append(stats, ClientBandwidthStats{
+			IP:            ip,
+			ActiveStreams: c.active,
+			RateKBps:      c.bytesPerSec / 1024,
+			TotalBytes:    c.totalBytes,
+			Exempt:        c.exempt,
+			LastSeen:      c.lastSeen.Unix(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].RateKBps > stats[j].RateKBps
+	})
+	return stats
+}
+
+// HandleBandwidthStatsAPI 处理 /api/admin/bandwidth 接口，返回实时带宽统计
+func (bl *BandwidthLimiter) HandleBandwidthStatsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clients := bl.Stats()
+	var totalKBps float64
+	for _, c := range clients {
+		totalKBps += c.RateKBps
+	}
+
+	exempt := make([]string, 0, len(bl.exempt))
+	for _, n := range bl.exempt {
+		exempt = append(exempt, n.String())
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"enabled":           bl.enabled,
+			"global_limit_kbps": bl.globalKBps,
+			"client_limit_kbps": bl.clientKBps,
+			"burst_kb":          bl.burst / 1024,
+			"exempt":            strings.Join(exempt, ", "),
+			"total_rate_kbps":   totalKBps,
+			"clients":           clients,
+		},
+		"count": len(clients),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// proxyBandwidth 代理使用的带宽限速器，为 nil 时不限速
+var proxyBandwidth *BandwidthLimiter
+
+// SetProxyBandwidthLimiter 设置代理使用的带宽限速器
+func SetProxyBandwidthLimiter(bl *BandwidthLimiter) {
+	proxyBandwidth = bl
+}
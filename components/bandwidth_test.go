@@ -0,0 +1,215 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+func newTestBandwidthLimiter(t *testing.T, enabled bool, clientKBps, globalKBps int, exempt string) *BandwidthLimiter {
+	t.Helper()
+	config := &utils.Config{}
+	config.Bandwidth.Enabled = enabled
+	config.Bandwidth.ClientLimitKBps = clientKBps
+	config.Bandwidth.GlobalLimitKBps = globalKBps
+	config.Bandwidth.BurstKB = 16
+	config.Bandwidth.Exempt = exempt
+	bl, err := NewBandwidthLimiter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bl
+}
+
+func TestBandwidthLimiterExempt(t *testing.T) {
+	bl := newTestBandwidthLimiter(t, true, 64, 0, "127.0.0.1, 10.0.0.0/8, ::1")
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"::1", true},
+		{"192.0.2.1", false},
+		{"11.0.0.1", false},
+		{"unknown", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := bl.isExempt(tt.ip); got != tt.want {
+			t.Errorf("isExempt(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestNewBandwidthLimiterInvalidExempt(t *testing.T) {
+	config := &utils.Config{}
+	config.Bandwidth.Exempt = "10.0.0.0/33"
+	if _, err := NewBandwidthLimiter(config); err == nil {
+		t.Error("无效的豁免列表应返回错误")
+	}
+}
+
+func TestBandwidthLimiterThrottle(t *testing.T) {
+	// 突发 16KB、每客户端 64KB/s：写入 48KB 至少需要 (48-16)/64 = 0.5 秒
+	const size = 48 * 1024
+	tests := []struct {
+		name     string
+		enabled  bool
+		clientIP string
+		minDur   time.Duration
+		maxDur   time.Duration
+	}{
+		{"限速客户端", true, "192.0.2.1", 400 * time.Millisecond, 2 * time.Second},
+		{"豁免客户端不限速", true, "127.0.0.1", 0, 200 * time.Millisecond},
+		{"未启用时不限速", false, "192.0.2.1", 0, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := newTestBandwidthLimiter(t, tt.enabled, 64, 0, "127.0.0.1")
+			var buf bytes.Buffer
+			w, release := bl.Wrap(context.Background(), &buf, tt.clientIP)
+			start := time.Now()
+			n, err := w.Write(make([]byte, size))
+			elapsed := time.Since(start)
+			release()
+			if err != nil || n != size {
+				t.Fatalf("Write() = %d, %v, want %d, nil", n, err, size)
+			}
+			if elapsed < tt.minDur || elapsed > tt.maxDur {
+				t.Errorf("耗时 %v, want [%v, %v]", elapsed, tt.minDur, tt.maxDur)
+			}
+			if buf.Len() != size {
+				t.Errorf("写入 %d 字节, want %d", buf.Len(), size)
+			}
+		})
+	}
+}
+
+func TestBandwidthLimiterCanceled(t *testing.T) {
+	bl := newTestBandwidthLimiter(t, true, 1, 0, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w, release := bl.Wrap(ctx, &bytes.Buffer{}, "192.0.2.1")
+	defer release()
+	n, err := w.Write(make([]byte, 64*1024))
+	if n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Write() = %d, %v, want 0, context.Canceled", n, err)
+	}
+}
+
+func TestBandwidthLimiterStats(t *testing.T) {
+	bl := newTestBandwidthLimiter(t, true, 0, 0, "127.0.0.1")
+	w, release := bl.Wrap(context.Background(), &bytes.Buffer{}, "192.0.2.1")
+	w.Write(make([]byte, 1000))
+	w2, release2 := bl.Wrap(context.Background(), &bytes.Buffer{}, "127.0.0.1")
+	w2.Write(make([]byte, 10))
+	release2()
+
+	stats := map[string]ClientBandwidthStats{}
+	for _, s := range bl.Stats() {
+		stats[s.IP] = s
+	}
+	if s := stats["192.0.2.1"]; s.ActiveStreams != 1 || s.TotalBytes != 1000 || s.Exempt {
+		t.Errorf("192.0.2.1 统计 = %+v", s)
+	}
+	if s := stats["127.0.0.1"]; s.ActiveStreams != 0 || s.TotalBytes != 10 || !s.Exempt {
+		t.Errorf("127.0.0.1 统计 = %+v", s)
+	}
+	release()
+
+	rec := httptest.NewRecorder()
+	bl.HandleBandwidthStatsAPI(rec, httptest.NewRequest("GET", "/api/admin/bandwidth", nil))
+	var resp struct {
+		Success bool `json:"success"`
+		Count   int  `json:"count"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success || resp.Count != 2 {
+		t.Errorf("统计接口响应 = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	bl.HandleBandwidthStatsAPI(rec, httptest.NewRequest("POST", "/api/admin/bandwidth", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestBandwidthLimiterUnknownClient(t *testing.T) {
+	// 无法确定客户端IP的流各自限速，不计入客户端统计
+	bl := newTestBandwidthLimiter(t, true, 64, 0, "")
+	w1, release1 := bl.Wrap(context.Background(), &bytes.Buffer{}, "")
+	w2, release2 := bl.Wrap(context.Background(), &bytes.Buffer{}, "")
+	defer release1()
+	defer release2()
+
+	// 两个流各自写满突发额度，不会因共用限速器而等待
+	start := time.Now()
+	for _, w := range []io.Writer{w1, w2} {
+		if _, err := w.Write(make([]byte, 16*1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("耗时 %v, 无法确定IP的流不应共用限速器", elapsed)
+	}
+	if stats := bl.Stats(); len(stats) != 0 {
+		t.Errorf("Stats() = %+v, want empty", stats)
+	}
+}
+
+func TestBandwidthLimiterSampleLoop(t *testing.T) {
+	bl := newTestBandwidthLimiter(t, true, 0, 0, "")
+	active := bl.acquire("192.0.2.1")
+	bl.record(active, 4096)
+	idle := bl.acquire("192.0.2.2")
+	bl.release(idle)
+	bl.mu.Lock()
+	idle.lastSeen = time.Now().Add(-2 * bandwidthClientIdleTTL)
+	bl.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bl.sampleLoop(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		bl.mu.Lock()
+		_, idleLeft := bl.clients["192.0.2.2"]
+		sampled := active.windowBytes == 0 // 采样后窗口计数清零
+		bl.mu.Unlock()
+		if !idleLeft && sampled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("采样未生效: 空闲客户端残留 = %v", idleLeft)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	bl.mu.Lock()
+	_, activeLeft := bl.clients["192.0.2.1"]
+	total := active.totalBytes
+	bl.mu.Unlock()
+	if !activeLeft || total != 4096 {
+		t.Errorf("活跃客户端不应被清理: 残留 = %v, total = %d", activeLeft, total)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ctx 取消后采样循环未退出")
+	}
+}
@@ -0,0 +1,220 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常放行
+	BreakerOpen     = "open"      // 熔断中，直接拒绝
+	BreakerHalfOpen = "half_open" // 冷却结束，放行一个探测请求
+)
+
+// CircuitBreaker 单个上游地址的熔断器
+// 连续失败达到阈值后熔断，冷却时间过后放行一个探测请求，成功则恢复，失败则重新熔断
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+// BreakerStatus 熔断器状态快照
+type BreakerStatus struct {
+	URL                 string `json:"url"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error,omitempty"`
+	RetryAfterSeconds   int    `json:"retry_after_seconds,omitempty"`
+}
+
+// Allow 判断是否放行请求；熔断中返回 false 以及剩余冷却时间
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		remaining := b.cooldown - time.Since(b.openedAt)
+		if remaining > 0 {
+			return false, remaining
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true, 0
+	case BreakerHalfOpen:
+		// 半开状态只放行一个探测请求
+		if b.probing {
+			return false, time.Second
+		}
+		b.probing = true
+		return true, 0
+	}
+	return true, 0
+}
+
+// Success 记录一次成功请求
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+	b.lastError = ""
+}
+
+// Failure 记录一次失败请求，返回本次失败是否导致熔断
+func (b *CircuitBreaker) Failure(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastError = err.Error()
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		opened := b.state != BreakerOpen
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+		return opened
+	}
+	return false
+}
+
+// status 返回熔断器状态快照
+func (b *CircuitBreaker) status(url string) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{
+		URL:                 url,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state == BreakerOpen {
+		if remaining := b.cooldown - time.Since(b.openedAt); remaining > 0 {
+			st.RetryAfterSeconds = int(remaining.Seconds()) + 1
+		}
+	}
+	return st
+}
+
+// BreakerGroup 按 视频源代码 + 地址 管理熔断器
+type BreakerGroup struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewBreakerGroup 创建熔断器组，threshold 为连续失败次数阈值，cooldown 为熔断冷却时间
+func NewBreakerGroup(threshold int, cooldown time.Duration) *BreakerGroup {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	return &BreakerGroup{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get 获取指定视频源地址的熔断器，不存在时创建
+func (g *BreakerGroup) Get(code, url string) *CircuitBreaker {
+	key := code + "|" + url
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.breakers[key]
+	if !ok {
+		b = &CircuitBreaker{
+			threshold: g.threshold,
+			cooldown:  g.cooldown,
+			state:     BreakerClosed,
+		}
+		g.breakers[key] = b
+	}
+	return b
+}
+
+// CircuitOpenError 视频源全部地址均处于熔断状态
+type CircuitOpenError struct {
+	Code       string
+	RetryAfter time.Duration
+	LastError  string
+}
+
+func (e *CircuitOpenError) Error() string {
+	msg := fmt.Sprintf("视频源 %s 已熔断，%d 秒后重试", e.Code, int(e.RetryAfter.Seconds())+1)
+	if e.LastError != "" {
+		msg += ": " + e.LastError
+	}
+	return msg
+}
+
+// sourceStatusView 视频源状态接口中的单个视频源
+type sourceStatusView struct {
+	Code      string          `json:"code"`
+	Name      string          `json:"name"`
+	State     string          `json:"state"`
+	Endpoints []BreakerStatus `json:"endpoints"`
+}
+
+// SourceStatus 返回视频源各地址的熔断状态，任一地址可用时整体视为 closed
+func (sc *SourcesConfig) SourceStatus(source *VideoSource) sourceStatusView {
+	view := sourceStatusView{
+		Code:  source.Code,
+		Name:  source.Name,
+		State: BreakerOpen,
+	}
+	for _, u := range source.Endpoints() {
+		st := sc.breakers.Get(source.Code, u).status(u)
+		view.Endpoints = append(view.Endpoints, st)
+		switch {
+		case st.State == BreakerClosed:
+			view.State = BreakerClosed
+		case st.State == BreakerHalfOpen && view.State == BreakerOpen:
+			view.State = BreakerHalfOpen
+		}
+	}
+	return view
+}
+
+// HandleSourceStatusAPI 处理 /api/source_status 接口，返回所有启用视频源的熔断状态
+func (sc *SourcesConfig) HandleSourceStatusAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sources := sc.EnabledSources()
+	views := make([]sourceStatusView, 0, len(sources))
+	for i := range sources {
+		views = append(views, sc.SourceStatus(&sources[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    views,
+		"count":   len(views),
+	})
+}
@@ -0,0 +1,120 @@
+package components
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	errUpstream := errors.New("upstream error")
+
+	// 每一步对熔断器执行的操作以及执行后的期望状态
+	type step struct {
+		op        string // allow、success、failure 或 wait
+		wantAllow bool   // op 为 allow 时的期望结果
+		wantOpen  bool   // op 为 failure 时是否导致熔断
+		wantState string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"未达阈值保持关闭", []step{
+			{op: "failure", wantState: BreakerClosed},
+			{op: "failure", wantState: BreakerClosed},
+			{op: "allow", wantAllow: true, wantState: BreakerClosed},
+		}},
+		{"成功清零失败计数", []step{
+			{op: "failure", wantState: BreakerClosed},
+			{op: "failure", wantState: BreakerClosed},
+			{op: "success", wantState: BreakerClosed},
+			{op: "failure", wantState: BreakerClosed},
+			{op: "failure", wantState: BreakerClosed},
+		}},
+		{"达到阈值熔断", []step{
+			{op: "failure", wantState: BreakerClosed},
+			{op: "failure", wantState: BreakerClosed},
+			{op: "failure", wantOpen: true, wantState: BreakerOpen},
+			{op: "allow", wantAllow: false, wantState: BreakerOpen},
+		}},
+		{"冷却后半开探测成功恢复", []step{
+			{op: "failure"}, {op: "failure"}, {op: "failure", wantOpen: true, wantState: BreakerOpen},
+			{op: "wait", wantState: BreakerOpen},
+			{op: "allow", wantAllow: true, wantState: BreakerHalfOpen},
+			{op: "allow", wantAllow: false, wantState: BreakerHalfOpen},
+			{op: "success", wantState: BreakerClosed},
+			{op: "allow", wantAllow: true, wantState: BreakerClosed},
+		}},
+		{"半开探测失败重新熔断", []step{
+			{op: "failure"}, {op: "failure"}, {op: "failure", wantOpen: true, wantState: BreakerOpen},
+			{op: "wait", wantState: BreakerOpen},
+			{op: "allow", wantAllow: true, wantState: BreakerHalfOpen},
+			{op: "failure", wantOpen: true, wantState: BreakerOpen},
+			{op: "allow", wantAllow: false, wantState: BreakerOpen},
+		}},
+		{"熔断中的失败不重复报告", []step{
+			{op: "failure"}, {op: "failure"}, {op: "failure", wantOpen: true, wantState: BreakerOpen},
+			{op: "failure", wantOpen: false, wantState: BreakerOpen},
+		}},
+	}
+
+	const cooldown = 20 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreakerGroup(3, cooldown).Get("src", "http://example.com/api")
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if ok, _ := b.Allow(); ok != s.wantAllow {
+						t.Fatalf("第 %d 步 Allow() = %v, want %v", i+1, ok, s.wantAllow)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					if opened := b.Failure(errUpstream); opened != s.wantOpen {
+						t.Fatalf("第 %d 步 Failure() = %v, want %v", i+1, opened, s.wantOpen)
+					}
+				case "wait":
+					time.Sleep(cooldown + 5*time.Millisecond)
+				}
+				if s.wantState != "" {
+					if st := b.status("").State; st != s.wantState {
+						t.Fatalf("第 %d 步（%s）后状态 = %s, want %s", i+1, s.op, st, s.wantState)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerStatus(t *testing.T) {
+	b := NewBreakerGroup(1, time.Minute).Get("src", "http://example.com/api")
+	b.Failure(errors.New("timeout"))
+
+	ok, retry := b.Allow()
+	if ok || retry <= 0 || retry > time.Minute {
+		t.Errorf("Allow() = %v, %v", ok, retry)
+	}
+	st := b.status("http://example.com/api")
+	if st.State != BreakerOpen || st.ConsecutiveFailures != 1 || st.LastError != "timeout" {
+		t.Errorf("status = %+v", st)
+	}
+	if st.RetryAfterSeconds < 1 || st.RetryAfterSeconds > 60 {
+		t.Errorf("RetryAfterSeconds = %d", st.RetryAfterSeconds)
+	}
+}
+
+func TestBreakerGroupGet(t *testing.T) {
+	g := NewBreakerGroup(0, 0)
+	if g.threshold != 3 || g.cooldown != time.Minute {
+		t.Errorf("默认值 threshold=%d cooldown=%v", g.threshold, g.cooldown)
+	}
+	a := g.Get("a", "http://example.com")
+	if g.Get("a", "http://example.com") != a {
+		t.Error("相同视频源和地址应返回同一个熔断器")
+	}
+	if g.Get("b", "http://example.com") == a {
+		t.Error("不同视频源应使用独立的熔断器")
+	}
+}
@@ -2,13 +2,17 @@ package components
 
 import (
 	"fmt"
-	"log"
 	"net"
 	"os/exec"
 	"runtime"
 	"time"
+
+	"vastproxy-go/utils"
 )
 
+// browserLog 浏览器启动日志
+var browserLog = utils.Logger("browser")
+
 // GetLocalIP 获取本地IP地址
 func GetLocalIP() string {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
@@ -35,18 +39,18 @@ func StartBrowser(url string) error {
 		browserName = "默认浏览器"
 	case "linux":
 		// Linux不自动打开浏览器
-		log.Printf("🐧 检测到 Linux 系统，不自动启动浏览器")
+		browserLog.Info("🐧 检测到 Linux 系统，不自动启动浏览器")
 		return nil
 	default:
 		return fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
 	}
 
 	if err != nil {
-		log.Printf("❌ 无法打开 %s: %v", browserName, err)
+		browserLog.Error("❌ 无法打开浏览器", "browser", browserName, "error", err)
 		return err
 	}
 
-	log.Printf("✅ 已使用 %s 打开: %s", browserName, url)
+	browserLog.Info("✅ 已打开浏览器", "browser", browserName, "url", url)
 	return nil
 }
 
@@ -55,9 +59,9 @@ func StartBrowserWithServer(url string, serverReady chan bool) error {
 	// 等待服务器就绪
 	select {
 	case <-serverReady:
-		log.Println("✅ 服务器已就绪，启动浏览器")
+		browserLog.Info("✅ 服务器已就绪，启动浏览器")
 	case <-time.After(10 * time.Second):
-		log.Println("⚠️ 等待服务器超时，尝试启动浏览器")
+		browserLog.Warn("⚠️ 等待服务器超时，尝试启动浏览器")
 	}
 
 	return StartBrowser(url)
@@ -0,0 +1,255 @@
+package components
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// cacheLog 响应缓存日志
+var cacheLog = utils.Logger("cache")
+
+// 缓存命中状态，通过 X-Cache 响应头返回
+const (
+	CacheHit   = "HIT"   // 新鲜缓存
+	CacheMiss  = "MISS"  // 未命中，已请求上游
+	CacheStale = "STALE" // 过期缓存，后台刷新中或上游出错
+)
+
+// CachePolicy 缓存策略
+type CachePolicy struct {
+	TTL                  time.Duration // 新鲜期
+	StaleWhileRevalidate time.Duration // 过期后仍可直接返回并在后台刷新的时长
+	StaleIfError         time.Duration // 过期后上游出错时仍可返回旧数据的时长
+}
+
+// Retention 返回条目写入后仍可能被使用的最长时长
+func (p CachePolicy) Retention() time.Duration {
+	stale := p.StaleWhileRevalidate
+	if p.StaleIfError > stale {
+		stale = p.StaleIfError
+	}
+	return p.TTL + stale
+}
+
+// CacheResult 缓存查询结果
+type CacheResult struct {
+	Value  []byte
+	Status string
+	Age    time.Duration // 数据已缓存的时长
+	MaxAge time.Duration // 剩余新鲜期
+	Policy CachePolicy
+}
+
+// cacheEntry 缓存条目，同时作为磁盘缓存的文件格式
+type cacheEntry struct {
+	Key      string    `json:"key"`
+	Value    []byte    `json:"value"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// cacheCall 正在进行中的上游请求，相同 key 的并发请求共享结果
+type cacheCall struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// ResponseCache 内存缓存（可选磁盘持久化），支持过期后台刷新和并发请求合并，超出上限时淘汰最久未使用的条目
+type ResponseCache struct {
+	name       string
+	maxEntries int
+	dir        string
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element // 值为 *cacheEntry
+	lru      *list.List               // 最近使用的条目在前
+	inflight map[string]*cacheCall
+}
+
+// NewResponseCache 创建缓存，dir 不为空时同时写入磁盘，重启后可继续使用
+func NewResponseCache(name string, maxEntries int, dir string) (*ResponseCache, error) {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建缓存目录失败: %v", err)
+		}
+	}
+	return &ResponseCache{
+		name:       name,
+		maxEntries: maxEntries,
+		dir:        dir,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		inflight:   make(map[string]*cacheCall),
+	}, nil
+}
+
+// Do 按策略返回 key 对应的数据，必要时调用 fetch 请求上游
+// 新鲜数据直接返回；过期但在 StaleWhileRevalidate 内时返回旧数据并在后台刷新；
+// 其他情况请求上游，失败时在 StaleIfError 内返回旧数据。相同 key 的并发请求只会请求一次上游
+func (c *ResponseCache) Do(key string, policy CachePolicy, fetch func() ([]byte, error)) (*CacheResult, error) {
+	entry := c.lookup(key)
+	if entry != nil {
+		age := time.Since(entry.StoredAt)
+		if age < policy.TTL {
+			return c.result(entry, CacheHit, policy), nil
+		}
+		if age < policy.TTL+policy.StaleWhileRevalidate {
+			go func() {
+				if _, err := c.fetchShared(key, fetch); err != nil {
+					cacheLog.Warn("⚠️ 缓存后台刷新失败", "cache", c.name, "error", err)
+				}
+			}()
+			return c.result(entry, CacheStale, policy), nil
+		}
+	}
+
+	value, err := c.fetchShared(key, fetch)
+	if err != nil {
+		if entry != nil && time.Since(entry.StoredAt) < policy.TTL+policy.StaleIfError {
+			cacheLog.Warn("⚠️ 上游请求失败，返回过期缓存", "cache", c.name, "error", err)
+			return c.result(entry, CacheStale, policy), nil
+		}
+		return nil, err
+	}
+	return &CacheResult{Value: value, Status: CacheMiss, MaxAge: policy.TTL, Policy: policy}, nil
+}
+
+// result 根据缓存条目构造查询结果
+func (c *ResponseCache) result(entry *cacheEntry, status string, policy CachePolicy) *CacheResult {
+	age := time.Since(entry.StoredAt)
+	maxAge := policy.TTL - age
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return &CacheResult{Value: entry.Value, Status: status, Age: age, MaxAge: maxAge, Policy: policy}
+}
+
+// fetchShared 请求上游并写入缓存，相同 key 的并发调用共享同一次请求
+func (c *ResponseCache) fetchShared(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.value, call.err = fetch()
+	if call.err == nil {
+		c.store(&cacheEntry{Key: key, Value: call.value, StoredAt: time.Now()})
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// lookup 查找缓存条目，内存中没有时尝试从磁盘读取
+func (c *ResponseCache) lookup(key string) *cacheEntry {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*cacheEntry)
+		c.mu.Unlock()
+		return entry
+	}
+	c.mu.Unlock()
+	if c.dir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.filePath(key))
+	if err != nil {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.Key != key {
+		return nil
+	}
+	c.mu.Lock()
+	c.putLocked(entry)
+	c.mu.Unlock()
+	return entry
+}
+
+// store 写入缓存条目
+func (c *ResponseCache) store(entry *cacheEntry) {
+	c.mu.Lock()
+	c.putLocked(entry)
+	c.mu.Unlock()
+
+	if c.dir != "" {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			err = os.WriteFile(c.filePath(entry.Key), data, 0644)
+		}
+		if err != nil {
+			cacheLog.Warn("⚠️ 缓存写入磁盘失败", "cache", c.name, "error", err)
+		}
+	}
+}
+
+// putLocked 写入内存缓存并标记为最近使用，超出上限时淘汰最久未使用的条目，调用方需持有锁
+func (c *ResponseCache) putLocked(entry *cacheEntry) {
+	if elem, ok := c.entries[entry.Key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[entry.Key] = c.lru.PushFront(entry)
+	}
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).Key)
+	}
+}
+
+// filePath 返回 key 对应的磁盘缓存文件路径
+func (c *ResponseCache) filePath(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Len 返回内存中的缓存条目数
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// WriteCacheHeaders 写出缓存提示响应头
+// X-Cache 表示命中状态，Age 为数据已缓存的秒数，Cache-Control 告知客户端可缓存的时长
+func WriteCacheHeaders(w http.ResponseWriter, res *CacheResult) {
+	writeCacheHeaders(w, res, "public")
+}
+
+// WritePrivateCacheHeaders 写出缓存提示响应头，用于按管理员会话返回不同内容（如内容过滤）的接口
+// 只允许浏览器缓存，避免共享缓存或 CDN 把一个用户的结果返回给其他用户
+func WritePrivateCacheHeaders(w http.ResponseWriter, res *CacheResult) {
+	writeCacheHeaders(w, res, "private")
+	w.Header().Add("Vary", "Cookie, Authorization")
+}
+
+func writeCacheHeaders(w http.ResponseWriter, res *CacheResult, scope string) {
+	w.Header().Set("X-Cache", res.Status)
+	w.Header().Set("Age", fmt.Sprintf("%d", int(res.Age.Seconds())))
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d",
+		scope, int(res.MaxAge.Seconds()), int(res.Policy.StaleWhileRevalidate.Seconds())))
+}
@@ -0,0 +1,87 @@
+package components
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 磁盘缓存清理间隔
+const cachePruneInterval = time.Hour
+
+// StartCacheDirPruner 启动后台任务，定时清理磁盘缓存目录，ctx 取消时退出
+func StartCacheDirPruner(ctx context.Context, name, dir string, maxAge time.Duration, maxBytes int64) {
+	go func() {
+		ticker := time.NewTicker(cachePruneInterval)
+		defer ticker.Stop()
+		for {
+			removed, err := pruneCacheDir(dir, maxAge, maxBytes)
+			if err != nil {
+				cacheLog.Warn("⚠️ 清理磁盘缓存失败", "cache", name, "dir", dir, "error", err)
+			} else if removed > 0 {
+				cacheLog.Info("🧹 已清理磁盘缓存", "cache", name, "dir", dir, "removed", removed)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pruneCacheDir 删除目录下修改时间超过 maxAge 的文件，剩余文件总大小超过 maxBytes 时从最旧的开始删除
+// maxAge、maxBytes 为 0 时不做对应限制，返回删除的文件数
+func pruneCacheDir(dir string, maxAge time.Duration, maxBytes int64) (int, error) {
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cacheFile
+	var total int64
+	removed := 0
+	now := time.Now()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if maxAge > 0 && now.Sub(info.ModTime()) > maxAge {
+			if os.Remove(path) == nil {
+				removed++
+			}
+			return nil
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil || maxBytes <= 0 || total <= maxBytes {
+		return removed, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			removed++
+			total -= f.size
+		}
+	}
+	return removed, nil
+}
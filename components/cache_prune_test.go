@@ -0,0 +1,87 @@
+package components
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPruneCacheDir(t *testing.T) {
+	// 测试文件: 名称、大小、修改时间距今的时长
+	type cacheFile struct {
+		name string
+		size int
+		age  time.Duration
+	}
+	files := []cacheFile{
+		{"a", 100, 72 * time.Hour},
+		{"b", 100, 3 * time.Hour},
+		{"sub/c", 100, 2 * time.Hour},
+		{"d", 100, time.Hour},
+	}
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		maxBytes    int64
+		wantRemoved int
+		wantLeft    []string
+	}{
+		{"不做限制", 0, 0, 0, []string{"a", "b", "d", "sub/c"}},
+		{"按修改时间清理", 48 * time.Hour, 0, 1, []string{"b", "d", "sub/c"}},
+		{"按总大小从最旧的开始清理", 0, 250, 2, []string{"d", "sub/c"}},
+		{"同时按时间和大小清理", 48 * time.Hour, 200, 2, []string{"d", "sub/c"}},
+		{"总大小未超限", 0, 400, 0, []string{"a", "b", "d", "sub/c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range files {
+				path := filepath.Join(dir, filepath.FromSlash(f.name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, make([]byte, f.size), 0644); err != nil {
+					t.Fatal(err)
+				}
+				modTime := time.Now().Add(-f.age)
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			removed, err := pruneCacheDir(dir, tt.maxAge, tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("removed = %d, want %d", removed, tt.wantRemoved)
+			}
+			var left []string
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(dir, path)
+					left = append(left, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			sort.Strings(left)
+			if len(left) != len(tt.wantLeft) {
+				t.Fatalf("剩余文件 %v, want %v", left, tt.wantLeft)
+			}
+			for i := range left {
+				if left[i] != tt.wantLeft[i] {
+					t.Fatalf("剩余文件 %v, want %v", left, tt.wantLeft)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneCacheDirMissing(t *testing.T) {
+	removed, err := pruneCacheDir(filepath.Join(t.TempDir(), "missing"), time.Hour, 1)
+	if err != nil || removed != 0 {
+		t.Errorf("pruneCacheDir() = %d, %v, want 0, nil", removed, err)
+	}
+}
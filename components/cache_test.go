@@ -0,0 +1,219 @@
+package components
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testCachePolicy = CachePolicy{
+	TTL:                  time.Minute,
+	StaleWhileRevalidate: time.Minute,
+	StaleIfError:         time.Hour,
+}
+
+func newTestCache(t *testing.T, dir string) *ResponseCache {
+	t.Helper()
+	c, err := NewResponseCache("test", 10, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestResponseCacheDo(t *testing.T) {
+	errUpstream := errors.New("upstream error")
+	tests := []struct {
+		name       string
+		age        time.Duration // 已有缓存的时长，-1 表示没有缓存
+		fetchErr   error
+		wantStatus string
+		wantValue  string
+		wantErr    bool
+	}{
+		{"未命中", -1, nil, CacheMiss, "new", false},
+		{"未命中且上游出错", -1, errUpstream, "", "", true},
+		{"新鲜缓存", 30 * time.Second, nil, CacheHit, "old", false},
+		{"过期但在后台刷新期内", 90 * time.Second, nil, CacheStale, "old", false},
+		{"超过后台刷新期重新请求", 3 * time.Minute, nil, CacheMiss, "new", false},
+		{"上游出错返回过期缓存", 3 * time.Minute, errUpstream, CacheStale, "old", false},
+		{"超过 stale-if-error 期限", 2 * time.Hour, errUpstream, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCache(t, "")
+			if tt.age >= 0 {
+				c.store(&cacheEntry{Key: "k", Value: []byte("old"), StoredAt: time.Now().Add(-tt.age)})
+			}
+			res, err := c.Do("k", testCachePolicy, func() ([]byte, error) {
+				return []byte("new"), tt.fetchErr
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Do() 应返回错误，得到 %+v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tt.wantStatus || string(res.Value) != tt.wantValue {
+				t.Errorf("Do() = %s %q, want %s %q", res.Status, res.Value, tt.wantStatus, tt.wantValue)
+			}
+			if res.Status == CacheStale && tt.fetchErr == nil {
+				// 后台刷新完成后返回新数据
+				deadline := time.Now().Add(time.Second)
+				for string(c.lookup("k").Value) != "new" {
+					if time.Now().After(deadline) {
+						t.Fatal("没有在后台刷新缓存")
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+				res, _ = c.Do("k", testCachePolicy, func() ([]byte, error) { return nil, errUpstream })
+				if res.Status != CacheHit || string(res.Value) != "new" {
+					t.Errorf("刷新后 Do() = %s %q, want HIT \"new\"", res.Status, res.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseCacheCoalescing(t *testing.T) {
+	c := newTestCache(t, "")
+	var calls int32
+	release := make(chan struct{})
+	fetch := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]*CacheResult, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := c.Do("k", testCachePolicy, fetch)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = res
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("并发请求调用上游 %d 次, want 1", calls)
+	}
+	for i, res := range results {
+		if res == nil || string(res.Value) != "value" {
+			t.Errorf("第 %d 个结果 = %+v", i, res)
+		}
+	}
+}
+
+func TestResponseCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCache(t, dir)
+	if _, err := c.Do("k", testCachePolicy, func() ([]byte, error) { return []byte("value"), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新实例从磁盘读取，不请求上游
+	c = newTestCache(t, dir)
+	res, err := c.Do("k", testCachePolicy, func() ([]byte, error) { return nil, errors.New("不应请求上游") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != CacheHit || string(res.Value) != "value" {
+		t.Errorf("Do() = %s %q, want HIT \"value\"", res.Status, res.Value)
+	}
+}
+
+func TestResponseCacheEvict(t *testing.T) {
+	c, err := NewResponseCache("test", 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, key := range []string{"a", "b", "c"} {
+		c.store(&cacheEntry{Key: key, StoredAt: now.Add(time.Duration(i) * time.Second)})
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+	if c.lookup("a") != nil {
+		t.Error("最早写入的条目应被淘汰")
+	}
+
+	// 读取过的条目变为最近使用，淘汰最久未使用的条目
+	if c.lookup("b") == nil {
+		t.Fatal("条目 b 应仍在缓存中")
+	}
+	c.store(&cacheEntry{Key: "d", StoredAt: now.Add(3 * time.Second)})
+	if c.lookup("c") != nil {
+		t.Error("最久未使用的条目应被淘汰")
+	}
+	if c.lookup("b") == nil || c.lookup("d") == nil {
+		t.Error("最近使用的条目不应被淘汰")
+	}
+
+	// 重复写入同一 key 不增加条目数
+	c.store(&cacheEntry{Key: "d", Value: []byte("new"), StoredAt: now.Add(4 * time.Second)})
+	if e := c.lookup("d"); c.Len() != 2 || e == nil || string(e.Value) != "new" {
+		t.Errorf("Len() = %d, lookup(d) = %+v", c.Len(), e)
+	}
+}
+
+func TestCachePolicyRetention(t *testing.T) {
+	tests := []struct {
+		policy CachePolicy
+		want   time.Duration
+	}{
+		{testCachePolicy, time.Minute + time.Hour},
+		{CachePolicy{TTL: time.Minute, StaleWhileRevalidate: time.Hour, StaleIfError: time.Second}, time.Minute + time.Hour},
+		{CachePolicy{TTL: time.Minute}, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Retention(); got != tt.want {
+			t.Errorf("%+v.Retention() = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCacheHeaders(t *testing.T) {
+	res := &CacheResult{Status: CacheHit, Age: 10 * time.Second, MaxAge: 50 * time.Second, Policy: testCachePolicy}
+	tests := []struct {
+		name        string
+		write       func(*httptest.ResponseRecorder)
+		wantControl string
+		wantVary    string
+	}{
+		{"公共缓存", func(w *httptest.ResponseRecorder) { WriteCacheHeaders(w, res) },
+			"public, max-age=50, stale-while-revalidate=60", ""},
+		{"私有缓存", func(w *httptest.ResponseRecorder) { WritePrivateCacheHeaders(w, res) },
+			"private, max-age=50, stale-while-revalidate=60", "Cookie, Authorization"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.write(w)
+		h := w.Header()
+		if h.Get("X-Cache") != CacheHit || h.Get("Age") != "10" {
+			t.Errorf("%s: X-Cache=%q Age=%q", tt.name, h.Get("X-Cache"), h.Get("Age"))
+		}
+		if got := h.Get("Cache-Control"); got != tt.wantControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.name, got, tt.wantControl)
+		}
+		if got := h.Get("Vary"); got != tt.wantVary {
+			t.Errorf("%s: Vary = %q, want %q", tt.name, got, tt.wantVary)
+		}
+	}
+}
@@ -4,30 +4,196 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"vastproxy-go/utils"
 )
 
+// doubanLog 豆瓣接口日志
+var doubanLog = utils.Logger("douban")
+
 // 豆瓣 API 响应结构体
 type DoubanTagsResponse struct {
 	Tags []string `json:"tags"`
 }
 
 type DoubanSubject struct {
-	Title string `json:"title"`
-	Rate  string `json:"rate"`
-	Cover string `json:"cover"`
-	URL   string `json:"url"`
+	ID           string  `json:"id"`
+	Title        string  `json:"title"`
+	Rate         string  `json:"rate"`   // 一位小数的评分文本，无评分时为空
+	Rating       float64 `json:"rating"` // 数值评分，无评分时为 0
+	Cover        string  `json:"cover"`
+	URL          string  `json:"url"`
+	IsNew        bool    `json:"is_new,omitempty"`
+	Playable     bool    `json:"playable,omitempty"`
+	EpisodesInfo string  `json:"episodes_info,omitempty"`
 }
 
 type DoubanSubjectsResponse struct {
 	Subjects []DoubanSubject `json:"subjects"`
 }
 
+// 豆瓣图片需要携带的 Referer
+const doubanImageReferer = "https://movie.douban.com/"
+
+// doubanProxyCovers 是否默认将封面地址改写为经由 /proxy 访问
+var doubanProxyCovers bool
+
+// doubanCoverEndpoint 改写封面时使用的代理路径
+var doubanCoverEndpoint = "/proxy"
+
+// SetDoubanProxyCovers 设置是否默认将封面地址改写为经由 /proxy 并携带豆瓣 Referer 访问
+func SetDoubanProxyCovers(enabled bool) {
+	doubanProxyCovers = enabled
+}
+
+// SetDoubanCoverEndpoint 设置改写封面时使用的代理路径，启用 /img 图片代理时封面改由其缓存
+func SetDoubanCoverEndpoint(endpoint string) {
+	doubanCoverEndpoint = endpoint
+}
+
+// proxyCoversRequested 判断本次请求是否改写封面地址，proxy_cover 参数优先于配置
+func proxyCoversRequested(r *http.Request) bool {
+	switch r.URL.Query().Get("proxy_cover") {
+	case "1", "true":
+		return true
+	case "0", "false":
+		return false
+	}
+	return doubanProxyCovers
+}
+
+// normalize 校验并规范化条目字段：评分统一为数值和一位小数文本，封面与链接统一为 https，
+// proxyCover 为 true 时封面改写为 /proxy（或 /img）地址。标题为空的条目视为无效
+func (s *DoubanSubject) normalize(proxyCover bool) bool {
+	s.Title = strings.TrimSpace(s.Title)
+	if s.Title == "" {
+		return false
+	}
+
+	if s.Rating == 0 {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(s.Rate), 64); err == nil {
+			s.Rating = v
+		}
+	}
+	if s.Rating < 0 || s.Rating > 10 {
+		s.Rating = 0
+	}
+	s.Rate = formatDoubanRate(s.Rating)
+
+	s.Cover = forceHTTPS(strings.TrimSpace(s.Cover))
+	s.URL = forceHTTPS(strings.TrimSpace(s.URL))
+	if proxyCover && s.Cover != "" {
+		s.Cover = doubanCoverEndpoint + "?url=" + url.QueryEscape(s.Cover) + "&referer=" + url.QueryEscape(doubanImageReferer)
+	}
+	return true
+}
+
+// forceHTTPS 将 http 地址改写为 https，其他地址原样返回
+func forceHTTPS(u string) string {
+	if strings.HasPrefix(u, "http://") {
+		return "https://" + strings.TrimPrefix(u, "http://")
+	}
+	return u
+}
+
+// doubanCache 豆瓣响应缓存，为 nil 时不缓存
+var doubanCache *ResponseCache
+
+// doubanPolicies 各 action 的缓存策略
+var doubanPolicies = map[string]CachePolicy{}
+
+// SetDoubanCache 设置豆瓣响应缓存及各 action 的缓存策略
+func SetDoubanCache(cache *ResponseCache, policies map[string]CachePolicy) {
+	doubanCache = cache
+	doubanPolicies = policies
+}
+
+// doubanBackoff 豆瓣限流退避状态
+// 豆瓣返回 403/429 时暂停请求，退避时长从 base 开始逐次翻倍直至 max，请求成功后重置
+type doubanBackoff struct {
+	base time.Duration
+	max  time.Duration
+
+	mu      sync.Mutex
+	current time.Duration
+	until   time.Time
+}
+
+// doubanLimiter 全局豆瓣限流退避状态
+var doubanLimiter = &doubanBackoff{base: 30 * time.Second, max: 10 * time.Minute}
+
+// SetDoubanBackoff 设置豆瓣限流退避的初始时长和最大时长
+func SetDoubanBackoff(base, max time.Duration) {
+	if base <= 0 || max < base {
+		return
+	}
+	doubanLimiter = &doubanBackoff{base: base, max: max}
+}
+
+// wait 返回剩余退避时长，为 0 表示可以请求
+func (b *doubanBackoff) wait() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Until(b.until)
+}
+
+// hit 记录一次限流，延长退避时长
+func (b *doubanBackoff) hit() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.current == 0 {
+		b.current = b.base
+	} else {
+		b.current *= 2
+	}
+	if b.current > b.max {
+		b.current = b.max
+	}
+	b.until = time.Now().Add(b.current)
+	return b.current
+}
+
+// reset 请求成功后重置退避状态
+func (b *doubanBackoff) reset() {
+	b.mu.Lock()
+	b.current = 0
+	b.until = time.Time{}
+	b.mu.Unlock()
+}
+
+// doubanClient 请求豆瓣接口使用的客户端
+var doubanClient = &http.Client{Timeout: 30 * time.Second}
+
+// doubanRateLimitedError 豆瓣限流中，请求未发出
+type doubanRateLimitedError struct {
+	retryAfter time.Duration
+}
+
+func (e *doubanRateLimitedError) Error() string {
+	return fmt.Sprintf("豆瓣限流退避中，%d 秒后重试", int(e.retryAfter.Seconds())+1)
+}
+
+// fetchDoubanCached 通过缓存获取豆瓣数据，未设置缓存时直接请求且返回的 CacheResult 为 nil
+func fetchDoubanCached(action, targetURL string) ([]byte, *CacheResult, error) {
+	if doubanCache == nil {
+		data, err := fetchDoubanData(targetURL)
+		return data, nil, err
+	}
+	res, err := doubanCache.Do("douban|"+targetURL, doubanPolicies[action], func() ([]byte, error) {
+		return fetchDoubanData(targetURL)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Value, res, nil
+}
+
 // DoubanHandler 处理豆瓣API请求
 func DoubanHandler(w http.ResponseWriter, r *http.Request, globalConfig interface{}) {
 	// 设置响应头
@@ -56,6 +222,12 @@ func DoubanHandler(w http.ResponseWriter, r *http.Request, globalConfig interfac
 		handleDoubanTags(w, r)
 	case "subjects":
 		handleDoubanSubjects(w, r)
+	case "detail":
+		handleDoubanDetail(w, r)
+	case "suggest":
+		handleDoubanSuggest(w, r)
+	case "chart":
+		handleDoubanChart(w, r)
 	default:
 		// 返回 API 使用说明
 		apiInfo := map[string]interface{}{
@@ -70,6 +242,18 @@ func DoubanHandler(w http.ResponseWriter, r *http.Request, globalConfig interfac
 					"url": "/douban?action=subjects&type=movie&tag=热门&page_limit=16&page_start=0",
 					"参数":  "type: movie 或 tv, tag: 标签名, page_limit: 每页数量, page_start: 起始位置",
 				},
+				"条目详情": map[string]string{
+					"url": "/douban?action=detail&type=movie&id=1292052",
+					"参数":  "type: movie 或 tv, id: 豆瓣条目ID",
+				},
+				"搜索建议": map[string]string{
+					"url": "/douban?action=suggest&q=肖申克",
+					"参数":  "q: 标题关键词",
+				},
+				"榜单": map[string]string{
+					"url": "/douban?action=chart&chart=top250&start=0&count=20",
+					"参数":  "chart: top250, movie_weekly, tv_weekly_chinese, tv_weekly_global, show_weekly, show_weekly_global, movie_real_time, tv_real_time",
+				},
 			},
 			"example": map[string]string{
 				"获取电影标签":  "/douban?action=tags&type=movie",
@@ -91,7 +275,7 @@ func handleDoubanTags(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if mediaType != "movie" && mediaType != "tv" {
-		http.Error(w, "Invalid type parameter. Use 'movie' or 'tv'", http.StatusBadRequest)
+		writeDoubanJSONError(w, http.StatusBadRequest, fmt.Errorf("Invalid type parameter. Use 'movie' or 'tv'"))
 		return
 	}
 
@@ -99,17 +283,34 @@ func handleDoubanTags(w http.ResponseWriter, r *http.Request) {
 	doubanURL := fmt.Sprintf("https://movie.douban.com/j/search_tags?type=%s", mediaType)
 
 	// 通过代理获取数据
-	data, err := fetchDoubanData(doubanURL)
+	data, cacheResult, err := fetchDoubanCached("tags", doubanURL)
 	if err != nil {
-		log.Printf("❌ 获取豆瓣标签失败: %v [IP:%s]", err, utils.GetRequestIP(r))
-		http.Error(w, "Failed to fetch douban tags", http.StatusInternalServerError)
+		doubanLog.ErrorContext(r.Context(), "❌ 获取豆瓣标签失败", "error", err)
+		writeDoubanJSONError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	// 解析并去除空白、重复的标签
+	var raw DoubanTagsResponse
+	if err := json.Unmarshal(data, &raw); err != nil {
+		doubanLog.ErrorContext(r.Context(), "❌ 解析豆瓣标签失败", "error", err)
+		writeDoubanJSONError(w, http.StatusBadGateway, fmt.Errorf("解析豆瓣标签失败"))
+		return
+	}
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range raw.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
 	// 返回数据
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
-	log.Printf("✅ 返回豆瓣 %s 标签数据 [IP:%s]", mediaType, utils.GetRequestIP(r))
+	writeDoubanJSON(w, cacheResult, tags, len(tags))
+	doubanLog.InfoContext(r.Context(), "✅ 返回豆瓣标签数据", "type", mediaType)
 }
 
 // handleDoubanSubjects 处理豆瓣推荐请求
@@ -117,15 +318,15 @@ func handleDoubanSubjects(w http.ResponseWriter, r *http.Request) {
 	// 获取查询参数
 	mediaType := r.URL.Query().Get("type")
 	tag := r.URL.Query().Get("tag")
-	pageLimit := r.URL.Query().Get("page_limit")
-	pageStart := r.URL.Query().Get("page_start")
+	pageLimit := atoiDefault(r.URL.Query().Get("page_limit"), 16)
+	pageStart := atoiDefault(r.URL.Query().Get("page_start"), 0)
 
 	// 参数验证和默认值
 	if mediaType == "" {
 		mediaType = "movie"
 	}
 	if mediaType != "movie" && mediaType != "tv" {
-		http.Error(w, "Invalid type parameter. Use 'movie' or 'tv'", http.StatusBadRequest)
+		writeDoubanJSONError(w, http.StatusBadRequest, fmt.Errorf("Invalid type parameter. Use 'movie' or 'tv'"))
 		return
 	}
 
@@ -133,34 +334,51 @@ func handleDoubanSubjects(w http.ResponseWriter, r *http.Request) {
 		tag = "热门"
 	}
 
-	if pageLimit == "" {
-		pageLimit = "16"
+	if pageLimit <= 0 || pageLimit > 100 {
+		pageLimit = 16
 	}
 
-	if pageStart == "" {
-		pageStart = "0"
+	if pageStart < 0 {
+		pageStart = 0
 	}
 
 	// 构建豆瓣 API URL
-	doubanURL := fmt.Sprintf("https://movie.douban.com/j/search_subjects?type=%s&tag=%s&sort=recommend&page_limit=%s&page_start=%s",
+	doubanURL := fmt.Sprintf("https://movie.douban.com/j/search_subjects?type=%s&tag=%s&sort=recommend&page_limit=%d&page_start=%d",
 		mediaType, url.QueryEscape(tag), pageLimit, pageStart)
 
 	// 通过代理获取数据
-	data, err := fetchDoubanData(doubanURL)
+	data, cacheResult, err := fetchDoubanCached("subjects", doubanURL)
 	if err != nil {
-		log.Printf("❌ 获取豆瓣推荐失败: %v [IP:%s]", err, utils.GetRequestIP(r))
-		http.Error(w, "Failed to fetch douban subjects", http.StatusInternalServerError)
+		doubanLog.ErrorContext(r.Context(), "❌ 获取豆瓣推荐失败", "error", err)
+		writeDoubanJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var raw DoubanSubjectsResponse
+	if err := json.Unmarshal(data, &raw); err != nil {
+		doubanLog.ErrorContext(r.Context(), "❌ 解析豆瓣推荐失败", "error", err)
+		writeDoubanJSONError(w, http.StatusBadGateway, fmt.Errorf("解析豆瓣推荐失败"))
 		return
 	}
+	proxyCover := proxyCoversRequested(r)
+	subjects := []DoubanSubject{}
+	for _, subject := range raw.Subjects {
+		if subject.normalize(proxyCover) {
+			subjects = append(subjects, subject)
+		}
+	}
 
 	// 返回数据
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
-	log.Printf("✅ 返回豆瓣 %s 推荐数据 (标签: %s, 数量: %s) [IP:%s]", mediaType, tag, pageLimit, utils.GetRequestIP(r))
+	writeDoubanJSON(w, cacheResult, subjects, len(subjects))
+	doubanLog.InfoContext(r.Context(), "✅ 返回豆瓣推荐数据", "type", mediaType, "tag", tag, "count", len(subjects))
 }
 
-// fetchDoubanData 获取豆瓣数据
+// fetchDoubanData 获取豆瓣数据，限流退避期间不发出请求
 func fetchDoubanData(targetURL string) ([]byte, error) {
+	if wait := doubanLimiter.wait(); wait > 0 {
+		return nil, &doubanRateLimitedError{retryAfter: wait}
+	}
+
 	// 构建请求
 	req, err := http.NewRequest("GET", targetURL, nil)
 	if err != nil {
@@ -169,22 +387,27 @@ func fetchDoubanData(targetURL string) ([]byte, error) {
 
 	// 设置请求头
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
-	req.Header.Set("Referer", "https://movie.douban.com/")
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-
-	// 创建客户端
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	// 移动版接口（rexxar）校验来自 m.douban.com 的 Referer
+	referer := "https://movie.douban.com/"
+	if u, err := url.Parse(targetURL); err == nil && u.Host == "m.douban.com" {
+		referer = "https://m.douban.com/"
 	}
+	req.Header.Set("Referer", referer)
+	req.Header.Set("Accept", "application/json, text/plain, */*")
 
 	// 发送请求
-	resp, err := client.Do(req)
+	resp, err := doubanClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
-	// 检查响应状态
+	// 检查响应状态，403/429 视为被豆瓣限流
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		backoff := doubanLimiter.hit()
+		doubanLog.Warn("🐢 豆瓣限流，暂停请求", "status", resp.StatusCode, "backoff", backoff)
+		return nil, &doubanRateLimitedError{retryAfter: backoff}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
 	}
@@ -195,5 +418,6 @@ func fetchDoubanData(targetURL string) ([]byte, error) {
 		return nil, fmt.Errorf("读取响应失败: %v", err)
 	}
 
+	doubanLimiter.reset()
 	return body, nil
 }
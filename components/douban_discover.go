@@ -0,0 +1,363 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DoubanSubjectDetail 豆瓣条目详情
+type DoubanSubjectDetail struct {
+	DoubanSubject
+	Type               string    `json:"type"` // movie 或 tv
+	Year               string    `json:"year"`
+	OriginalTitle      string    `json:"original_title,omitempty"`
+	Genres             []string  `json:"genres"`
+	Countries          []string  `json:"countries"`
+	Directors          []string  `json:"directors"`
+	Actors             []string  `json:"actors"`
+	Summary            string    `json:"summary"`
+	Duration           string    `json:"duration,omitempty"`
+	EpisodesCount      int       `json:"episodes_count,omitempty"`
+	RatingCount        int       `json:"rating_count"`
+	RatingDistribution []float64 `json:"rating_distribution,omitempty"` // 依次为 1~5 星的占比（百分比）
+}
+
+// DoubanSuggestion 豆瓣搜索建议
+type DoubanSuggestion struct {
+	DoubanSubject
+	Type     string `json:"type"`
+	Year     string `json:"year"`
+	SubTitle string `json:"sub_title,omitempty"`
+	Episode  string `json:"episode,omitempty"`
+}
+
+// DoubanChartItem 豆瓣榜单条目
+type DoubanChartItem struct {
+	DoubanSubject
+	Type     string `json:"type"`
+	Year     string `json:"year"`
+	Rank     int    `json:"rank"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+// doubanCharts 支持的榜单及对应的豆瓣片单 ID
+var doubanCharts = map[string]string{
+	"top250":             "movie_top250",
+	"movie_weekly":       "movie_weekly_best",
+	"tv_weekly_chinese":  "tv_chinese_best_weekly",
+	"tv_weekly_global":   "tv_global_best_weekly",
+	"show_weekly":        "show_chinese_best_weekly",
+	"movie_real_time":    "movie_real_time_hotest",
+	"tv_real_time":       "tv_real_time_hotest",
+	"show_weekly_global": "show_global_best_weekly",
+}
+
+// 豆瓣条目 ID 只包含数字
+var doubanIDPattern = regexp.MustCompile(`^[0-9]{1,12}$`)
+
+// handleDoubanDetail 处理豆瓣条目详情请求
+func handleDoubanDetail(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	mediaType := r.URL.Query().Get("type")
+	if mediaType == "" {
+		mediaType = "movie"
+	}
+	if !doubanIDPattern.MatchString(id) || (mediaType != "movie" && mediaType != "tv") {
+		writeDoubanJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid id or type parameter"))
+		return
+	}
+
+	data, cacheResult, err := fetchDoubanCached("detail",
+		fmt.Sprintf("https://m.douban.com/rexxar/api/v2/%s/%s", mediaType, id))
+	if err != nil {
+		doubanLog.ErrorContext(r.Context(), "❌ 获取豆瓣详情失败", "id", id, "error", err)
+		writeDoubanJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var raw struct {
+		ID            string   `json:"id"`
+		Title         string   `json:"title"`
+		OriginalTitle string   `json:"original_title"`
+		Type          string   `json:"type"`
+		Year          string   `json:"year"`
+		URL           string   `json:"url"`
+		Intro         string   `json:"intro"`
+		EpisodesCount int      `json:"episodes_count"`
+		Durations     []string `json:"durations"`
+		Genres        []string `json:"genres"`
+		Countries     []string `json:"countries"`
+		Pic           struct {
+			Large  string `json:"large"`
+			Normal string `json:"normal"`
+		} `json:"pic"`
+		Rating *struct {
+			Value float64 `json:"value"`
+			Count int     `json:"count"`
+		} `json:"rating"`
+		Directors []struct {
+			Name string `json:"name"`
+		} `json:"directors"`
+		Actors []struct {
+			Name string `json:"name"`
+		} `json:"actors"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || raw.ID == "" {
+		writeDoubanJSONError(w, http.StatusBadGateway, fmt.Errorf("解析豆瓣详情失败"))
+		return
+	}
+
+	detail := DoubanSubjectDetail{
+		DoubanSubject: DoubanSubject{
+			ID:    raw.ID,
+			Title: raw.Title,
+			Cover: raw.Pic.Large,
+			URL:   raw.URL,
+		},
+		Type:          raw.Type,
+		Year:          raw.Year,
+		OriginalTitle: raw.OriginalTitle,
+		Genres:        nonNilStrings(raw.Genres),
+		Countries:     nonNilStrings(raw.Countries),
+		Directors:     []string{},
+		Actors:        []string{},
+		Summary:       strings.TrimSpace(raw.Intro),
+		EpisodesCount: raw.EpisodesCount,
+	}
+	if detail.Cover == "" {
+		detail.Cover = raw.Pic.Normal
+	}
+	if len(raw.Durations) > 0 {
+		detail.Duration = raw.Durations[0]
+	}
+	if raw.Rating != nil {
+		detail.Rating = raw.Rating.Value
+		detail.RatingCount = raw.Rating.Count
+	}
+	for _, d := range raw.Directors {
+		detail.Directors = append(detail.Directors, d.Name)
+	}
+	for _, a := range raw.Actors {
+		detail.Actors = append(detail.Actors, a.Name)
+	}
+
+	// 评分分布单独获取，失败时不影响详情；rexxar 返回的 stats 为 0~1 的占比
+	if ratingData, _, err := fetchDoubanCached("detail",
+		fmt.Sprintf("https://m.douban.com/rexxar/api/v2/%s/%s/rating", mediaType, id)); err == nil {
+		var rating struct {
+			Stats []float64 `json:"stats"`
+		}
+		if json.Unmarshal(ratingData, &rating) == nil && len(rating.Stats) == 5 {
+			for _, p := range rating.Stats {
+				detail.RatingDistribution = append(detail.RatingDistribution, roundPercent(p))
+			}
+		}
+	}
+
+	detail.normalize(proxyCoversRequested(r))
+	writeDoubanJSON(w, cacheResult, detail, 1)
+	doubanLog.InfoContext(r.Context(), "✅ 返回豆瓣详情", "id", id, "title", detail.Title)
+}
+
+// handleDoubanSuggest 处理豆瓣标题搜索建议请求
+func handleDoubanSuggest(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeDoubanJSONError(w, http.StatusBadRequest, fmt.Errorf("missing q parameter"))
+		return
+	}
+
+	data, cacheResult, err := fetchDoubanCached("suggest",
+		"https://movie.douban.com/j/subject_suggest?q="+url.QueryEscape(q))
+	if err != nil {
+		doubanLog.ErrorContext(r.Context(), "❌ 获取豆瓣搜索建议失败", "error", err)
+		writeDoubanJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var raw []struct {
+		ID       string `json:"id"`
+		Title    string `json:"title"`
+		SubTitle string `json:"sub_title"`
+		Type     string `json:"type"`
+		Year     string `json:"year"`
+		Img      string `json:"img"`
+		URL      string `json:"url"`
+		Episode  string `json:"episode"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		writeDoubanJSONError(w, http.StatusBadGateway, fmt.Errorf("解析豆瓣搜索建议失败"))
+		return
+	}
+
+	proxyCover := proxyCoversRequested(r)
+	suggestions := []DoubanSuggestion{}
+	for _, item := range raw {
+		// 豆瓣建议中还可能包含影人等其他类型，只保留影视条目
+		if item.Type != "movie" && item.Type != "tv" {
+			continue
+		}
+		suggestion := DoubanSuggestion{
+			DoubanSubject: DoubanSubject{
+				ID:    item.ID,
+				Title: item.Title,
+				Cover: item.Img,
+				URL:   item.URL,
+			},
+			Type:     item.Type,
+			Year:     item.Year,
+			SubTitle: item.SubTitle,
+			Episode:  item.Episode,
+		}
+		if suggestion.normalize(proxyCover) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	writeDoubanJSON(w, cacheResult, suggestions, len(suggestions))
+	doubanLog.InfoContext(r.Context(), "✅ 返回豆瓣搜索建议", "q", q, "count", len(suggestions))
+}
+
+// handleDoubanChart 处理豆瓣榜单请求
+func handleDoubanChart(w http.ResponseWriter, r *http.Request) {
+	chart := r.URL.Query().Get("chart")
+	if chart == "" {
+		chart = "top250"
+	}
+	collection, ok := doubanCharts[chart]
+	if !ok {
+		writeDoubanJSONError(w, http.StatusBadRequest, fmt.Errorf("unknown chart: %s", chart))
+		return
+	}
+	start := atoiDefault(r.URL.Query().Get("start"), 0)
+	count := atoiDefault(r.URL.Query().Get("count"), 20)
+	if start < 0 {
+		start = 0
+	}
+	if count <= 0 || count > 50 {
+		count = 20
+	}
+
+	data, cacheResult, err := fetchDoubanCached("chart",
+		fmt.Sprintf("https://m.douban.com/rexxar/api/v2/subject_collection/%s/items?start=%d&count=%d", collection, start, count))
+	if err != nil {
+		doubanLog.ErrorContext(r.Context(), "❌ 获取豆瓣榜单失败", "chart", chart, "error", err)
+		writeDoubanJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var raw struct {
+		Total int `json:"total"`
+		Items []struct {
+			ID           string `json:"id"`
+			Title        string `json:"title"`
+			Type         string `json:"type"`
+			Year         string `json:"year"`
+			URL          string `json:"url"`
+			CardSubtitle string `json:"card_subtitle"`
+			Rank         int    `json:"rank"`
+			Pic          struct {
+				Large  string `json:"large"`
+				Normal string `json:"normal"`
+			} `json:"pic"`
+			Rating *struct {
+				Value float64 `json:"value"`
+			} `json:"rating"`
+		} `json:"subject_collection_items"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		writeDoubanJSONError(w, http.StatusBadGateway, fmt.Errorf("解析豆瓣榜单失败"))
+		return
+	}
+
+	proxyCover := proxyCoversRequested(r)
+	items := []DoubanChartItem{}
+	for i, item := range raw.Items {
+		chartItem := DoubanChartItem{
+			DoubanSubject: DoubanSubject{
+				ID:    item.ID,
+				Title: item.Title,
+				Cover: item.Pic.Normal,
+				URL:   item.URL,
+			},
+			Type:     item.Type,
+			Year:     item.Year,
+			Rank:     item.Rank,
+			Subtitle: item.CardSubtitle,
+		}
+		if chartItem.Cover == "" {
+			chartItem.Cover = item.Pic.Large
+		}
+		if chartItem.Rank == 0 {
+			chartItem.Rank = start + i + 1
+		}
+		if item.Rating != nil {
+			chartItem.Rating = item.Rating.Value
+		}
+		if chartItem.normalize(proxyCover) {
+			items = append(items, chartItem)
+		}
+	}
+
+	writeDoubanJSON(w, cacheResult, map[string]interface{}{
+		"chart": chart,
+		"total": raw.Total,
+		"items": items,
+	}, len(items))
+	doubanLog.InfoContext(r.Context(), "✅ 返回豆瓣榜单", "chart", chart, "count", len(items))
+}
+
+// formatDoubanRate 将评分格式化为一位小数，无评分时返回空字符串
+func formatDoubanRate(value float64) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
+
+// roundPercent 将 0~1 的占比换算为百分比并保留一位小数，如 0.2346 → 23.5
+func roundPercent(fraction float64) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(fraction*100, 'f', 1, 64), 64)
+	return v
+}
+
+// nonNilStrings 将 nil 切片转换为空切片，保证 JSON 输出为 []
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// writeDoubanJSON 以统一格式写出豆瓣数据
+func writeDoubanJSON(w http.ResponseWriter, cacheResult *CacheResult, data interface{}, count int) {
+	if cacheResult != nil {
+		WriteCacheHeaders(w, cacheResult)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "获取成功",
+		"data":    data,
+		"count":   count,
+	})
+}
+
+// writeDoubanJSONError 以统一格式写出错误，限流退避中返回 503 和 Retry-After
+func writeDoubanJSONError(w http.ResponseWriter, status int, err error) {
+	if rl, ok := err.(*doubanRateLimitedError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(rl.retryAfter.Seconds())+1))
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": err.Error(),
+		"data":    nil,
+	})
+}
@@ -0,0 +1,88 @@
+package components
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRoundPercent(t *testing.T) {
+	tests := []struct {
+		fraction float64
+		want     float64
+	}{
+		{0, 0},
+		{1, 100},
+		{0.2346, 23.5},
+		{0.5, 50},
+		{0.012, 1.2},
+		{0.0004, 0},
+	}
+	for _, tt := range tests {
+		if got := roundPercent(tt.fraction); got != tt.want {
+			t.Errorf("roundPercent(%v) = %v, want %v", tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestDoubanDetail(t *testing.T) {
+	useDoubanUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rexxar/api/v2/movie/1291546":
+			w.Write([]byte(`{"id":"1291546","title":"霸王别姬","type":"movie","year":"1993",
+				"url":"https://movie.douban.com/subject/1291546/","intro":" 简介 ","durations":["171分钟"],
+				"genres":["剧情","爱情"],"pic":{"large":"http://img1.doubanio.com/l.jpg","normal":"http://img1.doubanio.com/n.jpg"},
+				"rating":{"value":9.6,"count":2000000},"directors":[{"name":"陈凯歌"}],"actors":[{"name":"张国荣"},{"name":"张丰毅"}]}`))
+		case "/rexxar/api/v2/movie/1291546/rating":
+			w.Write([]byte(`{"stats":[0.002,0.004,0.031,0.2346,0.7285]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	w, resp := doubanRequest(t, "action=detail&type=movie&id=1291546")
+	if w.Code != http.StatusOK || resp["success"] != true || resp["count"] != float64(1) {
+		t.Fatalf("响应 = %s", w.Body.String())
+	}
+	detail := resp["data"].(map[string]interface{})
+	want := map[string]interface{}{
+		"title":        "霸王别姬",
+		"rate":         "9.6",
+		"rating":       9.6,
+		"rating_count": float64(2000000),
+		"cover":        "https://img1.doubanio.com/l.jpg",
+		"summary":      "简介",
+		"duration":     "171分钟",
+		"directors":    []interface{}{"陈凯歌"},
+		"countries":    []interface{}{},
+		// stats 为 0~1 的占比，换算为百分比
+		"rating_distribution": []interface{}{0.2, 0.4, 3.1, 23.5, 72.9},
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(detail[k], v) {
+			t.Errorf("%s = %v, want %v", k, detail[k], v)
+		}
+	}
+}
+
+func TestDoubanDetailInvalid(t *testing.T) {
+	useDoubanUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"无效的 ID", "action=detail&id=abc", http.StatusBadRequest},
+		{"无效的类型", "action=detail&type=book&id=1", http.StatusBadRequest},
+		{"无法解析的详情", "action=detail&id=1", http.StatusBadGateway},
+		{"未知的榜单", "action=chart&chart=none", http.StatusBadRequest},
+		{"缺少搜索关键词", "action=suggest&q=%20", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w, _ := doubanRequest(t, tt.query); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
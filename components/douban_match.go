@@ -0,0 +1,311 @@
+package components
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 低于该置信度的候选不返回
+const minMatchConfidence = 0.4
+
+// DoubanMatch 豆瓣条目在视频源中的匹配结果
+type DoubanMatch struct {
+	SourceCode string    `json:"source_code"`
+	SourceName string    `json:"source_name"`
+	Confidence float64   `json:"confidence"` // 0~1
+	Reasons    []string  `json:"reasons"`
+	Item       VideoItem `json:"item"`
+}
+
+// doubanMatchRequest 匹配请求，与豆瓣条目字段对应
+type doubanMatchRequest struct {
+	Title string `json:"title"`
+	Year  string `json:"year"`
+	Type  string `json:"type"` // movie 或 tv，为空时不参与评分
+}
+
+// SetMatchCache 设置豆瓣匹配结果缓存
+func (sc *SourcesConfig) SetMatchCache(cache *ResponseCache, policy CachePolicy) {
+	sc.matchCache = cache
+	sc.matchPolicy = policy
+}
+
+// HandleDoubanMatchAPI 处理 /api/douban_match 接口
+// 根据豆瓣条目的标题、年份和类型在所有启用的视频源中查找可播放的匹配，按置信度排序返回
+// GET 使用 title/year/type 查询参数，POST 使用 DoubanSubject 格式的请求体
+func (sc *SourcesConfig) HandleDoubanMatchAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+AdminPasswordHeader)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req doubanMatchRequest
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		req = doubanMatchRequest{Title: q.Get("title"), Year: q.Get("year"), Type: q.Get("type")}
+	case "POST":
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			writeMatchError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Year = strings.TrimSpace(req.Year)
+	if req.Title == "" {
+		writeMatchError(w, http.StatusBadRequest, "Missing title parameter")
+		return
+	}
+	// 只含空白和标点的标题归一化后为空，无法匹配任何结果，不再请求视频源
+	if normalizeTitle(req.Title) == "" {
+		writeMatchError(w, http.StatusBadRequest, "Invalid title parameter")
+		return
+	}
+	if req.Type != "" && req.Type != "movie" && req.Type != "tv" {
+		writeMatchError(w, http.StatusBadRequest, "Invalid type parameter. Use 'movie' or 'tv'")
+		return
+	}
+	limit := atoiDefault(r.URL.Query().Get("limit"), 5)
+	if limit <= 0 || limit > 20 {
+		limit = 5
+	}
+
+	filterActive := sc.filter != nil && sc.filter.Active(r)
+	var matches []DoubanMatch
+	var cacheResult *CacheResult
+	if sc.matchCache == nil {
+		matches = sc.matchDouban(req, filterActive)
+	} else {
+		key := strings.Join([]string{"match", req.Title, req.Year, req.Type, strconv.FormatBool(filterActive)}, "|")
+		res, err := sc.matchCache.Do(key, sc.matchPolicy, func() ([]byte, error) {
+			return json.Marshal(sc.matchDouban(req, filterActive))
+		})
+		if err == nil {
+			err = json.Unmarshal(res.Value, &matches)
+		}
+		if err != nil {
+			writeMatchError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		cacheResult = res
+	}
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []DoubanMatch{}
+	}
+	if cacheResult != nil {
+		WritePrivateCacheHeaders(w, cacheResult)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "匹配成功",
+		"data":    matches,
+		"count":   len(matches),
+	})
+	doubanLog.InfoContext(r.Context(), "✅ 豆瓣条目匹配完成", "title", req.Title, "year", req.Year, "count", len(matches))
+}
+
+// matchDouban 在所有启用的视频源中搜索标题，返回按置信度降序排列的可播放候选
+func (sc *SourcesConfig) matchDouban(req doubanMatchRequest, filterActive bool) []DoubanMatch {
+	sources := sc.EnabledSources()
+	results := make([][]DoubanMatch, len(sources))
+	var wg sync.WaitGroup
+	for i := range sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			source := &sources[i]
+			items, _, err := sc.cachedSearch(source, req.Title, "")
+			if err != nil {
+				return
+			}
+			if filterActive {
+				items, _ = sc.filter.FilterItems(source, items)
+			}
+			for _, item := range items {
+				if strings.TrimSpace(item.VodPlayUrl) == "" {
+					continue
+				}
+				confidence, reasons := scoreDoubanMatch(req, item)
+				if confidence < minMatchConfidence {
+					continue
+				}
+				item.SourceCode = source.Code
+				item.SourceName = source.Name
+				results[i] = append(results[i], DoubanMatch{
+					SourceCode: source.Code,
+					SourceName: source.Name,
+					Confidence: confidence,
+					Reasons:    reasons,
+					Item:       item,
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 按视频源顺序合并后稳定排序，置信度相同时靠前的源优先
+	var matches []DoubanMatch
+	for _, list := range results {
+		matches = append(matches, list...)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+	return matches
+}
+
+// scoreDoubanMatch 计算候选与豆瓣条目的匹配置信度
+// 标题最多 0.6 分，年份最多 0.25 分，类型最多 0.15 分；年份或类型明确不符时扣分
+func scoreDoubanMatch(req doubanMatchRequest, item VideoItem) (float64, []string) {
+	var score float64
+	var reasons []string
+
+	want := normalizeTitle(req.Title)
+	got := normalizeTitle(item.VodName)
+	switch {
+	case want == got:
+		score += 0.6
+		reasons = append(reasons, "标题一致")
+	case got != "" && (strings.Contains(got, want) || strings.Contains(want, got)):
+		score += 0.4
+		reasons = append(reasons, "标题包含")
+	default:
+		sim := titleSimilarity(want, got)
+		score += 0.5 * sim
+		reasons = append(reasons, "标题相似度 "+strconv.FormatFloat(sim, 'f', 2, 64))
+	}
+
+	if req.Year != "" && item.VodYear != "" {
+		wantYear, err1 := strconv.Atoi(req.Year)
+		gotYear, err2 := strconv.Atoi(strings.TrimSpace(item.VodYear))
+		if err1 == nil && err2 == nil {
+			switch diff := wantYear - gotYear; {
+			case diff == 0:
+				score += 0.25
+				reasons = append(reasons, "年份一致")
+			case diff == 1 || diff == -1:
+				score += 0.1
+				reasons = append(reasons, "年份相差一年")
+			default:
+				score -= 0.2
+				reasons = append(reasons, "年份不符")
+			}
+		}
+	}
+
+	if req.Type != "" {
+		if itemType := classifyTypeName(item.TypeName); itemType != "" {
+			if itemType == req.Type {
+				score += 0.15
+				reasons = append(reasons, "类型一致")
+			} else {
+				score -= 0.15
+				reasons = append(reasons, "类型不符")
+			}
+		}
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	if score > 1 {
+		score = 1
+	}
+	score, _ = strconv.ParseFloat(strconv.FormatFloat(score, 'f', 2, 64), 64)
+	return score, reasons
+}
+
+// normalizeTitle 归一化标题：转小写，全角转半角，去除空白和标点
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		// 全角字符转半角
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// titleSimilarity 基于编辑距离的标题相似度，0~1
+func titleSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// classifyTypeName 根据 MacCMS 分类名推断条目类型，无法判断时返回空字符串
+// 以“片”结尾的分类（如剧情片、动作片）视为电影，优先于“剧”字判断
+func classifyTypeName(typeName string) string {
+	switch {
+	case typeName == "":
+		return ""
+	case strings.HasSuffix(typeName, "片"), strings.Contains(typeName, "电影"):
+		return "movie"
+	case strings.Contains(typeName, "剧"), strings.Contains(typeName, "综艺"),
+		strings.Contains(typeName, "动漫"), strings.Contains(typeName, "番"):
+		return "tv"
+	}
+	return ""
+}
+
+// minInt 返回较小的整数
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// writeMatchError 写出匹配接口的错误响应
+func writeMatchError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+		"data":    []DoubanMatch{},
+	})
+}
@@ -0,0 +1,167 @@
+package components
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"流浪地球", "流浪地球"},
+		{"流浪地球 2：", "流浪地球2"},
+		{"Ｔｈｅ Ｍａｔｒｉｘ", "thematrix"},
+		{"Spider-Man: No Way Home", "spidermannowayhome"},
+		{"《三体》第一季", "三体第一季"},
+		{"  ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeTitle(tt.title); got != tt.want {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"甄嬛传", "甄嬛传", 1},
+		{"甄嬛传", "甄環传", 2.0 / 3},
+		{"abc", "xyz", 0},
+		{"abcd", "ab", 0.5},
+		{"", "abc", 0},
+	}
+	for _, tt := range tests {
+		if got := titleSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("titleSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyTypeName(t *testing.T) {
+	tests := []struct {
+		typeName string
+		want     string
+	}{
+		{"科幻片", "movie"},
+		{"剧情片", "movie"},
+		{"电影", "movie"},
+		{"国产剧", "tv"},
+		{"综艺", "tv"},
+		{"日本动漫", "tv"},
+		{"新番", "tv"},
+		{"纪录", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := classifyTypeName(tt.typeName); got != tt.want {
+			t.Errorf("classifyTypeName(%q) = %q, want %q", tt.typeName, got, tt.want)
+		}
+	}
+}
+
+func TestScoreDoubanMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         doubanMatchRequest
+		item        VideoItem
+		want        float64
+		wantReasons string
+	}{
+		{"标题年份类型全部一致",
+			doubanMatchRequest{Title: "流浪地球", Year: "2019", Type: "movie"},
+			VideoItem{VodName: "流浪地球", VodYear: "2019", TypeName: "科幻片"},
+			1, "标题一致,年份一致,类型一致"},
+		{"标题包含但年份不符",
+			doubanMatchRequest{Title: "流浪地球", Year: "2019", Type: "movie"},
+			VideoItem{VodName: "流浪地球2", VodYear: "2023", TypeName: "科幻片"},
+			0.35, "标题包含,年份不符,类型一致"},
+		{"全角标题且缺少年份",
+			doubanMatchRequest{Title: "The Matrix", Year: "1999"},
+			VideoItem{VodName: "Ｔｈｅ Ｍａｔｒｉｘ"},
+			0.6, "标题一致"},
+		{"年份相差一年且类型不符",
+			doubanMatchRequest{Title: "流浪地球", Year: "2019", Type: "tv"},
+			VideoItem{VodName: "流浪地球", VodYear: " 2020 ", TypeName: "动作片"},
+			0.55, "标题一致,年份相差一年,类型不符"},
+		{"相似标题",
+			doubanMatchRequest{Title: "甄嬛传", Year: "2011", Type: "tv"},
+			VideoItem{VodName: "甄環传", VodYear: "2011", TypeName: "国产剧"},
+			0.73, "标题相似度 0.67,年份一致,类型一致"},
+		{"无法判断的类型不参与评分",
+			doubanMatchRequest{Title: "地球脉动", Type: "tv"},
+			VideoItem{VodName: "地球脉动", TypeName: "纪录"},
+			0.6, "标题一致"},
+		{"完全不相关时不低于 0",
+			doubanMatchRequest{Title: "abc", Year: "2019", Type: "movie"},
+			VideoItem{VodName: "xyz", VodYear: "2000", TypeName: "国产剧"},
+			0, "标题相似度 0.00,年份不符,类型不符"},
+		{"候选标题为空",
+			doubanMatchRequest{Title: "流浪地球"},
+			VideoItem{},
+			0, "标题相似度 0.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reasons := scoreDoubanMatch(tt.req, tt.item)
+			if got != tt.want {
+				t.Errorf("score = %v, want %v", got, tt.want)
+			}
+			if r := strings.Join(reasons, ","); r != tt.wantReasons {
+				t.Errorf("reasons = %q, want %q", r, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestHandleDoubanMatchAPIValidation(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"code":1,"list":[{"vod_name":"流浪地球","vod_year":"2019","type_name":"科幻片"}]}`)
+	}))
+	defer srv.Close()
+	sc := NewSourcesConfig()
+	if err := sc.LoadFromConfigFile([]byte("[sources]\na.name = 源 A\na.url = " + srv.URL + "/\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		query        string
+		body         string
+		wantStatus   int
+		wantUpstream bool
+	}{
+		{"正常匹配", "GET", "title=" + url.QueryEscape("流浪地球"), "", http.StatusOK, true},
+		{"缺少标题", "GET", "", "", http.StatusBadRequest, false},
+		{"标题只有空白", "GET", "title=" + url.QueryEscape("   "), "", http.StatusBadRequest, false},
+		{"标题只有标点", "GET", "title=" + url.QueryEscape("《》：!?"), "", http.StatusBadRequest, false},
+		{"POST 标题只有标点", "POST", "", `{"title":"——，。"}`, http.StatusBadRequest, false},
+		{"无效的类型", "GET", "title=" + url.QueryEscape("流浪地球") + "&type=anime", "", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&requests, 0)
+		r := httptest.NewRequest(tt.method, "/api/douban_match?"+tt.query, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		sc.HandleDoubanMatchAPI(w, r)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d, body = %s", tt.name, w.Code, tt.wantStatus, w.Body.String())
+		}
+		if got := atomic.LoadInt32(&requests) > 0; got != tt.wantUpstream {
+			t.Errorf("%s: 是否请求了视频源 = %v, want %v", tt.name, got, tt.wantUpstream)
+		}
+	}
+}
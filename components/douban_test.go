@@ -0,0 +1,205 @@
+package components
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rewriteTransport 将请求转发到测试服务器，保留原始路径和查询参数
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = rt.target.Scheme
+	r.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// useDoubanUpstream 将豆瓣请求转发到 handler，并重置缓存与限流退避状态，测试结束后恢复
+func useDoubanUpstream(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	target, _ := url.Parse(srv.URL)
+
+	client, limiter, cache, proxyCovers := doubanClient, doubanLimiter, doubanCache, doubanProxyCovers
+	doubanClient = &http.Client{Transport: rewriteTransport{target: target}, Timeout: 5 * time.Second}
+	doubanLimiter = &doubanBackoff{base: 30 * time.Second, max: 10 * time.Minute}
+	doubanCache = nil
+	doubanProxyCovers = false
+	t.Cleanup(func() {
+		srv.Close()
+		doubanClient, doubanLimiter, doubanCache, doubanProxyCovers = client, limiter, cache, proxyCovers
+	})
+}
+
+// doubanRequest 请求 /douban 接口并解析统一格式的响应
+func doubanRequest(t *testing.T, query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	DoubanHandler(w, httptest.NewRequest("GET", "/douban?"+query, nil), nil)
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("无效的响应 %q: %v", w.Body.String(), err)
+	}
+	return w, resp
+}
+
+func TestDoubanBackoff(t *testing.T) {
+	b := &doubanBackoff{base: 30 * time.Second, max: 100 * time.Second}
+	if b.wait() > 0 {
+		t.Error("初始状态不应退避")
+	}
+	for i, want := range []time.Duration{30 * time.Second, 60 * time.Second, 100 * time.Second, 100 * time.Second} {
+		if got := b.hit(); got != want {
+			t.Errorf("第 %d 次限流退避 %v, want %v", i+1, got, want)
+		}
+	}
+	if wait := b.wait(); wait <= 90*time.Second || wait > 100*time.Second {
+		t.Errorf("wait() = %v", wait)
+	}
+	b.reset()
+	if b.wait() > 0 || b.hit() != 30*time.Second {
+		t.Error("重置后应从初始时长重新开始")
+	}
+}
+
+func TestDoubanRateLimited(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusTooManyRequests} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			var requests int32
+			useDoubanUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(status)
+			})
+
+			for i := 0; i < 2; i++ {
+				w, resp := doubanRequest(t, "action=tags&type=movie")
+				if w.Code != http.StatusServiceUnavailable {
+					t.Fatalf("第 %d 次请求 status = %d, want 503", i+1, w.Code)
+				}
+				retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+				if err != nil || retry < 1 || retry > 31 {
+					t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+				}
+				if resp["success"] != false {
+					t.Errorf("响应 = %v", resp)
+				}
+			}
+			// 退避期间不再请求豆瓣
+			if n := atomic.LoadInt32(&requests); n != 1 {
+				t.Errorf("上游请求次数 = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestDoubanUpstreamError(t *testing.T) {
+	useDoubanUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	w, _ := doubanRequest(t, "action=tags&type=movie")
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Retry-After") != "" {
+		t.Errorf("status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if doubanLimiter.wait() > 0 {
+		t.Error("其他错误不应触发退避")
+	}
+}
+
+func TestDoubanSubjectNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		subject    DoubanSubject
+		proxyCover bool
+		wantOK     bool
+		want       DoubanSubject
+	}{
+		{"解析文本评分并统一为 https",
+			DoubanSubject{Title: " 霸王别姬 ", Rate: "9.6", Cover: "http://img1.doubanio.com/a.jpg", URL: "http://movie.douban.com/subject/1/"},
+			false, true,
+			DoubanSubject{Title: "霸王别姬", Rate: "9.6", Rating: 9.6, Cover: "https://img1.doubanio.com/a.jpg", URL: "https://movie.douban.com/subject/1/"}},
+		{"数值评分格式化为一位小数",
+			DoubanSubject{Title: "a", Rating: 8},
+			false, true,
+			DoubanSubject{Title: "a", Rate: "8.0", Rating: 8}},
+		{"无评分",
+			DoubanSubject{Title: "a", Rate: ""},
+			false, true,
+			DoubanSubject{Title: "a"}},
+		{"超出范围的评分视为无评分",
+			DoubanSubject{Title: "a", Rate: "11"},
+			false, true,
+			DoubanSubject{Title: "a"}},
+		{"改写封面地址",
+			DoubanSubject{Title: "a", Cover: "http://img1.doubanio.com/a.jpg?x=1"},
+			true, true,
+			DoubanSubject{Title: "a", Cover: "/proxy?url=https%3A%2F%2Fimg1.doubanio.com%2Fa.jpg%3Fx%3D1&referer=https%3A%2F%2Fmovie.douban.com%2F"}},
+		{"没有封面时不改写",
+			DoubanSubject{Title: "a"},
+			true, true,
+			DoubanSubject{Title: "a"}},
+		{"标题为空", DoubanSubject{Title: "  ", Rate: "9.0"}, false, false, DoubanSubject{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.subject
+			if ok := s.normalize(tt.proxyCover); ok != tt.wantOK {
+				t.Fatalf("normalize() = %v, want %v", ok, tt.wantOK)
+			}
+			if tt.wantOK && s != tt.want {
+				t.Errorf("normalize() = %+v, want %+v", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoubanSubjectsEnvelope(t *testing.T) {
+	useDoubanUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/j/search_subjects" || r.URL.Query().Get("tag") != "热门" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"subjects":[
+			{"id":"1","title":"霸王别姬","rate":"9.6","cover":"http://img1.doubanio.com/a.jpg","url":"http://movie.douban.com/subject/1/"},
+			{"id":"2","title":"","rate":"8.0"}
+		]}`))
+	})
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCover string
+	}{
+		{"默认不改写封面", "", "https://img1.doubanio.com/a.jpg"},
+		{"proxy_cover=1 时改写封面", "&proxy_cover=1", "/proxy?url=https%3A%2F%2Fimg1.doubanio.com%2Fa.jpg&referer=https%3A%2F%2Fmovie.douban.com%2F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, resp := doubanRequest(t, "action=subjects&type=movie"+tt.query)
+			if w.Code != http.StatusOK || resp["success"] != true || resp["message"] != "获取成功" || resp["count"] != float64(1) {
+				t.Fatalf("响应 = %s", w.Body.String())
+			}
+			data, _ := resp["data"].([]interface{})
+			if len(data) != 1 {
+				t.Fatalf("data = %v", resp["data"])
+			}
+			subject := data[0].(map[string]interface{})
+			if subject["cover"] != tt.wantCover || subject["rating"] != 9.6 || subject["rate"] != "9.6" {
+				t.Errorf("subject = %v", subject)
+			}
+		})
+	}
+
+	w, resp := doubanRequest(t, "action=subjects&type=book")
+	if w.Code != http.StatusBadRequest || resp["success"] != false {
+		t.Errorf("无效的 type: %s", w.Body.String())
+	}
+}
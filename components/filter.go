@@ -0,0 +1,140 @@
+package components
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+
+	"vastproxy-go/utils"
+
+	"golang.org/x/time/rate"
+)
+
+// AdminPasswordHeader 绕过内容过滤时携带管理员密码的请求头
+const AdminPasswordHeader = "X-Admin-Password"
+
+// ContentFilter 服务端成人内容过滤器
+// 过滤始终在服务端执行，[filter] default_adult_filter 只决定管理员会话的初始过滤状态
+type ContentFilter struct {
+	blockedTypes map[string]bool
+	keywords     []string
+	auth         *AuthManager
+
+	// 请求头密码校验：bcrypt 较慢，限制每秒校验次数，并缓存最近一次校验通过的密码摘要
+	bypassLimiter *rate.Limiter
+	digestKey     []byte
+	mu            sync.Mutex
+	verified      []byte
+}
+
+// NewContentFilter 根据配置创建内容过滤器
+func NewContentFilter(config *utils.Config, auth *AuthManager) *ContentFilter {
+	cf := &ContentFilter{
+		blockedTypes:  make(map[string]bool),
+		auth:          auth,
+		bypassLimiter: rate.NewLimiter(5, 10),
+		digestKey:     make([]byte, 32),
+	}
+	if _, err := rand.Read(cf.digestKey); err != nil {
+		// 无法生成摘要密钥时不缓存校验结果，每次都走 bcrypt
+		cf.digestKey = nil
+	}
+	for _, t := range splitList(config.Filter.BlockedTypes) {
+		cf.blockedTypes[t] = true
+	}
+	for _, k := range splitList(config.Filter.BlockedKeywords) {
+		cf.keywords = append(cf.keywords, strings.ToLower(k))
+	}
+	return cf
+}
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Active 判断请求是否需要执行过滤
+// 默认始终过滤，只有请求头携带正确的管理员密码，或管理员会话中关闭了过滤时才会绕过
+func (cf *ContentFilter) Active(r *http.Request) bool {
+	if cf.auth == nil {
+		return true
+	}
+	if password := r.Header.Get(AdminPasswordHeader); password != "" && cf.verifyPassword(r, password) {
+		return false
+	}
+	return cf.auth.AdultFilterEnabled(r)
+}
+
+// verifyPassword 校验请求头中的管理员密码
+// 与最近一次校验通过的密码相同时直接比较摘要，否则按限速调用 bcrypt，超出限速时视为校验失败
+func (cf *ContentFilter) verifyPassword(r *http.Request, password string) bool {
+	var digest []byte
+	if cf.digestKey != nil {
+		mac := hmac.New(sha256.New, cf.digestKey)
+		mac.Write([]byte(password))
+		digest = mac.Sum(nil)
+
+		cf.mu.Lock()
+		cached := cf.verified
+		cf.mu.Unlock()
+		if cached != nil && hmac.Equal(cached, digest) {
+			return true
+		}
+	}
+
+	if !cf.bypassLimiter.Allow() {
+		authLog.Warn("⚠️ 管理员密码校验过于频繁，本次请求按未认证处理", "ip", utils.GetRequestIP(r))
+		return false
+	}
+	if !cf.auth.VerifyPassword(password) {
+		return false
+	}
+	if digest != nil {
+		cf.mu.Lock()
+		cf.verified = digest
+		cf.mu.Unlock()
+	}
+	return true
+}
+
+// Blocked 判断视频项是否应被过滤
+func (cf *ContentFilter) Blocked(item *VideoItem) bool {
+	if cf.blockedTypes[strings.TrimSpace(item.TypeName)] {
+		return true
+	}
+	if len(cf.keywords) == 0 {
+		return false
+	}
+	text := strings.ToLower(item.VodName + " " + item.TypeName)
+	for _, k := range cf.keywords {
+		if strings.Contains(text, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterItems 过滤视频列表，返回保留的视频和被过滤的数量
+// 标记为成人源的视频源，其结果全部过滤
+func (cf *ContentFilter) FilterItems(source *VideoSource, items []VideoItem) ([]VideoItem, int) {
+	if source != nil && source.Adult {
+		return []VideoItem{}, len(items)
+	}
+	kept := make([]VideoItem, 0, len(items))
+	for i := range items {
+		if cf.Blocked(&items[i]) {
+			continue
+		}
+		kept = append(kept, items[i])
+	}
+	return kept, len(items) - len(kept)
+}
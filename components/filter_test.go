@@ -0,0 +1,221 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vastproxy-go/utils"
+)
+
+func newTestContentFilter(t *testing.T, am *AuthManager) *ContentFilter {
+	t.Helper()
+	config := &utils.Config{}
+	config.Filter.DefaultAdultFilter = true
+	config.Filter.BlockedTypes = "伦理片, 福利 ,,"
+	config.Filter.BlockedKeywords = "Adult, 写真"
+	return NewContentFilter(config, am)
+}
+
+func TestContentFilterBlocked(t *testing.T) {
+	cf := newTestContentFilter(t, nil)
+	tests := []struct {
+		name string
+		item VideoItem
+		want bool
+	}{
+		{"普通影片", VideoItem{VodName: "流浪地球", TypeName: "科幻片"}, false},
+		{"屏蔽分类", VideoItem{VodName: "某片", TypeName: "伦理片"}, true},
+		{"分类两侧空格", VideoItem{VodName: "某片", TypeName: " 福利 "}, true},
+		{"名称关键词", VideoItem{VodName: "写真合集", TypeName: "综艺"}, true},
+		{"关键词忽略大小写", VideoItem{VodName: "ADULT show", TypeName: "综艺"}, true},
+		{"分类关键词", VideoItem{VodName: "某片", TypeName: "写真集"}, true},
+	}
+	for _, tt := range tests {
+		if got := cf.Blocked(&tt.item); got != tt.want {
+			t.Errorf("%s: Blocked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContentFilterFilterItems(t *testing.T) {
+	cf := newTestContentFilter(t, nil)
+	items := []VideoItem{
+		{VodName: "流浪地球", TypeName: "科幻片"},
+		{VodName: "某片", TypeName: "伦理片"},
+		{VodName: "满江红", TypeName: "剧情片"},
+	}
+
+	kept, blocked := cf.FilterItems(&VideoSource{Code: "a"}, items)
+	if blocked != 1 || len(kept) != 2 || kept[0].VodName != "流浪地球" || kept[1].VodName != "满江红" {
+		t.Errorf("普通源: kept = %v, blocked = %d", kept, blocked)
+	}
+
+	kept, blocked = cf.FilterItems(&VideoSource{Code: "b", Adult: true}, items)
+	if blocked != len(items) || kept == nil || len(kept) != 0 {
+		t.Errorf("成人源: kept = %v, blocked = %d", kept, blocked)
+	}
+
+	kept, blocked = cf.FilterItems(nil, nil)
+	if blocked != 0 || kept == nil || len(kept) != 0 {
+		t.Errorf("空列表: kept = %v, blocked = %d", kept, blocked)
+	}
+}
+
+func TestContentFilterActive(t *testing.T) {
+	am := newTestAuthManager(t, false)
+	token := login(am, "secret").Result().Cookies()[0].Value
+	cf := newTestContentFilter(t, am)
+
+	// 已登录会话关闭过滤
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/filter_config", strings.NewReader(`{"adult_filter":false}`))
+	r.Header.Set("Authorization", "Bearer "+token)
+	am.HandleFilterConfigAPI(w, r)
+	if w.Code != 200 {
+		t.Fatalf("关闭过滤 status = %d", w.Code)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		bearer   string
+		want     bool
+	}{
+		{"匿名请求", "", "", true},
+		{"请求头密码正确", "secret", "", false},
+		{"请求头密码错误", "wrong", "", true},
+		{"缓存后再次使用正确密码", "secret", "", false},
+		{"会话已关闭过滤", "", token, false},
+		{"无效会话", "", "invalid", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/source_search", nil)
+		if tt.password != "" {
+			r.Header.Set(AdminPasswordHeader, tt.password)
+		}
+		if tt.bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.bearer)
+		}
+		if got := cf.Active(r); got != tt.want {
+			t.Errorf("%s: Active = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if !newTestContentFilter(t, nil).Active(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("未配置认证时应始终过滤")
+	}
+}
+
+func TestContentFilterDefaultOff(t *testing.T) {
+	// default_adult_filter = false 只影响管理员会话的初始状态，匿名请求仍然过滤
+	config := &utils.Config{}
+	config.Auth.PasswordHash = testPasswordHash(t, "secret")
+	am, err := NewAuthManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf := NewContentFilter(config, am)
+
+	if !cf.Active(httptest.NewRequest("GET", "/api/source_search", nil)) {
+		t.Error("匿名请求应始终过滤")
+	}
+	r := httptest.NewRequest("GET", "/api/source_search", nil)
+	r.AddCookie(login(am, "secret").Result().Cookies()[0])
+	if cf.Active(r) {
+		t.Error("管理员会话应使用 default_adult_filter 作为初始状态")
+	}
+}
+
+func TestContentFilterPasswordThrottle(t *testing.T) {
+	cf := newTestContentFilter(t, newTestAuthManager(t, false))
+	request := func(password string) *http.Request {
+		r := httptest.NewRequest("GET", "/api/source_search", nil)
+		r.Header.Set(AdminPasswordHeader, password)
+		return r
+	}
+
+	// 耗尽校验配额后，错误密码与未缓存的正确密码都按未认证处理
+	for i := 0; i < cf.bypassLimiter.Burst()+1; i++ {
+		cf.Active(request("wrong"))
+	}
+	if !cf.Active(request("secret")) {
+		t.Error("超出限速时不应校验密码")
+	}
+
+	// 校验通过的密码缓存摘要后不再受限速影响
+	cf = newTestContentFilter(t, newTestAuthManager(t, false))
+	if cf.Active(request("secret")) {
+		t.Fatal("正确的密码应绕过过滤")
+	}
+	for i := 0; i < cf.bypassLimiter.Burst()+1; i++ {
+		cf.Active(request("wrong"))
+	}
+	if cf.Active(request("secret")) {
+		t.Error("已缓存的密码不应受限速影响")
+	}
+}
+
+// testFilterList 包含一个普通影片和一个屏蔽分类影片的 MacCMS 列表
+const testFilterList = `{"code":1,"list":[{"vod_name":"流浪地球","type_name":"科幻片"},{"vod_name":"某片","type_name":"伦理片"}]}`
+
+func TestSourceSearchFilter(t *testing.T) {
+	var keywords []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keywords = append(keywords, r.URL.Query().Get("wd"))
+		fmt.Fprint(w, testFilterList)
+	}))
+	defer srv.Close()
+
+	sc := NewSourcesConfig()
+	config := fmt.Sprintf("[sources]\na.name = 源 A\na.url = %s/\nb.name = 源 B\nb.url = %s/\nb.adult = true\n", srv.URL, srv.URL)
+	if err := sc.LoadFromConfigFile([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	sc.SetContentFilter(newTestContentFilter(t, newTestAuthManager(t, false)))
+
+	tests := []struct {
+		name         string
+		query        string
+		password     string
+		wantNames    string
+		wantFiltered int
+		wantKeyword  string
+	}{
+		{"搜索", "source=a&keyword=片", "", "流浪地球", 1, "片"},
+		{"最新推荐", "source=a&latest=true", "", "流浪地球", 1, ""},
+		{"搜索时密码错误", "source=a&keyword=片", "wrong", "流浪地球", 1, "片"},
+		{"最新推荐时密码错误", "source=a&latest=true", "wrong", "流浪地球", 1, ""},
+		{"搜索时携带管理员密码", "source=a&keyword=片", "secret", "流浪地球,某片", 0, "片"},
+		{"最新推荐时携带管理员密码", "source=a&latest=true", "secret", "流浪地球,某片", 0, ""},
+		{"成人源的搜索结果全部过滤", "source=b&keyword=片", "", "", 2, "片"},
+		{"成人源的最新推荐全部过滤", "source=b&latest=true", "", "", 2, ""},
+	}
+	for _, tt := range tests {
+		keywords = nil
+		r := httptest.NewRequest("GET", "/api/source_search?"+tt.query, nil)
+		if tt.password != "" {
+			r.Header.Set(AdminPasswordHeader, tt.password)
+		}
+		w := httptest.NewRecorder()
+		sc.HandleSourceSearchAPI(w, r)
+
+		var resp SearchResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", tt.name, w.Code, w.Body.String())
+		}
+		var names []string
+		for _, item := range resp.Data {
+			names = append(names, item.VodName)
+		}
+		if got := strings.Join(names, ","); got != tt.wantNames || resp.Filtered != tt.wantFiltered || resp.Count != len(resp.Data) {
+			t.Errorf("%s: 结果 = %q, filtered = %d, count = %d, want %q, %d", tt.name, got, resp.Filtered, resp.Count, tt.wantNames, tt.wantFiltered)
+		}
+		if len(keywords) != 1 || keywords[0] != tt.wantKeyword {
+			t.Errorf("%s: 上游关键词 = %q, want %q", tt.name, keywords, tt.wantKeyword)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package components
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// 注册 GIF/WebP 解码器，原图为这些格式时统一转码为 JPEG/PNG
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"vastproxy-go/utils"
+)
+
+// imageLog 图片代理日志
+var imageLog = utils.Logger("image")
+
+// 内置的图片防盗链 Referer，键为域名后缀
+var defaultImageReferers = map[string]string{
+	"doubanio.com": doubanImageReferer,
+	"douban.com":   doubanImageReferer,
+}
+
+// maxImagePixels 允许解码的图片像素上限（4000 万像素），防止伪造尺寸的小文件耗尽内存
+const maxImagePixels = 40_000_000
+
+// ImageProxy /img 图片代理：按域名携带 Referer 获取图片，原图与缩放结果缓存到磁盘
+type ImageProxy struct {
+	dir          string
+	cacheTTL     time.Duration
+	clientMaxAge int
+	maxWidth     int
+	quality      int
+	maxSource    int64
+	cacheMax     int64
+	referers     map[string]string
+	client       *http.Client
+
+	mu       sync.Mutex
+	inflight map[string]*cacheCall
+}
+
+// NewImageProxy 根据 [image] 配置创建图片代理
+func NewImageProxy(config *utils.Config) (*ImageProxy, error) {
+	c := config.Image
+	p := &ImageProxy{
+		dir:          c.CacheDir,
+		cacheTTL:     time.Duration(c.CacheTTLHours) * time.Hour,
+		clientMaxAge: c.ClientMaxAgeSeconds,
+		maxWidth:     c.MaxWidth,
+		quality:      c.JPEGQuality,
+		maxSource:    int64(c.MaxSourceMB) << 20,
+		cacheMax:     int64(c.CacheMaxMB) << 20,
+		referers:     make(map[string]string),
+		client:       &http.Client{Timeout: 20 * time.Second},
+		inflight:     make(map[string]*cacheCall),
+	}
+	if p.dir == "" {
+		p.dir = "cache/images"
+	}
+	if p.cacheTTL <= 0 {
+		p.cacheTTL = 7 * 24 * time.Hour
+	}
+	if p.clientMaxAge <= 0 {
+		p.clientMaxAge = 30 * 24 * 3600
+	}
+	if p.maxWidth <= 0 {
+		p.maxWidth = 1080
+	}
+	if p.quality <= 0 || p.quality > 100 {
+		p.quality = 82
+	}
+	if p.maxSource <= 0 {
+		p.maxSource = 10 << 20
+	}
+	if p.cacheMax <= 0 {
+		p.cacheMax = 1 << 30
+	}
+	for host, referer := range defaultImageReferers {
+		p.referers[host] = referer
+	}
+	for _, rule := range strings.Split(c.Referers, ",") {
+		host, referer, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			continue
+		}
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			p.referers[host] = strings.TrimSpace(referer)
+		}
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建图片缓存目录失败: %v", err)
+	}
+	return p, nil
+}
+
+// StartCachePruner 启动后台任务，定时删除过期的磁盘缓存，并将缓存目录控制在 cache_max_mb 以内
+func (p *ImageProxy) StartCachePruner(ctx context.Context) {
+	StartCacheDirPruner(ctx, "图片", p.dir, p.cacheTTL, p.cacheMax)
+}
+
+// imageVariant 一次图片请求的输出参数
+type imageVariant struct {
+	width   int    // 目标宽度，0 表示不缩放
+	format  string // jpeg、png，空表示保持原格式（非 JPEG/PNG 时转为 JPEG）
+	quality int
+}
+
+// HandleImageAPI 处理 /img 接口
+// 参数: url 图片地址，w 目标宽度（只缩小不放大），format=jpeg/png，q JPEG 质量，referer 覆盖默认 Referer
+func (p *ImageProxy) HandleImageAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	target, err := url.Parse(strings.TrimSpace(q.Get("url")))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "Invalid url parameter", http.StatusBadRequest)
+		return
+	}
+	variant := imageVariant{quality: p.quality}
+	if v := q.Get("w"); v != "" {
+		width, err := strconv.Atoi(v)
+		if err != nil || width < 0 {
+			http.Error(w, "Invalid w parameter", http.StatusBadRequest)
+			return
+		}
+		if width > p.maxWidth {
+			width = p.maxWidth
+		}
+		variant.width = width
+	}
+	switch format := strings.ToLower(q.Get("format")); format {
+	case "", "jpeg", "png":
+		variant.format = format
+	case "jpg":
+		variant.format = "jpeg"
+	default:
+		http.Error(w, "Invalid format parameter. Use 'jpeg' or 'png'", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("q"); v != "" {
+		quality, err := strconv.Atoi(v)
+		if err != nil || quality < 1 || quality > 100 {
+			http.Error(w, "Invalid q parameter", http.StatusBadRequest)
+			return
+		}
+		variant.quality = quality
+	}
+	referer := p.refererFor(target)
+	if v := q.Get("referer"); v != "" {
+		if u, err := url.Parse(v); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			referer = v
+		}
+	}
+
+	data, status, err := p.variant(target.String(), referer, variant)
+	if err != nil {
+		imageLog.WarnContext(r.Context(), "❌ 获取图片失败", "url", target.String(), "error", err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	sum := sha1.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:10]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", p.clientMaxAge))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write(data)
+}
+
+// refererFor 返回请求图片时携带的 Referer：命中域名规则时使用规则，否则使用图片自身的站点根地址
+func (p *ImageProxy) refererFor(target *url.URL) string {
+	host := strings.ToLower(target.Hostname())
+	for suffix, referer := range p.referers {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return referer
+		}
+	}
+	return target.Scheme + "://" + target.Host + "/"
+}
+
+// variant 返回指定参数的图片数据，缩放结果与原图都缓存在磁盘上
+func (p *ImageProxy) variant(rawURL, referer string, v imageVariant) ([]byte, int, error) {
+	key := fmt.Sprintf("%s|w=%d|f=%s|q=%d", rawURL, v.width, v.format, v.quality)
+	path := p.cachePath(key, ".img")
+	if data, ok := p.readCache(path); ok {
+		return data, http.StatusOK, nil
+	}
+
+	status := http.StatusOK
+	data, err := p.shared(key, func() ([]byte, error) {
+		src, err := p.original(rawURL, referer)
+		if err != nil {
+			status = http.StatusBadGateway
+			return nil, err
+		}
+		out, err := transformImage(src, v)
+		if err != nil {
+			status = http.StatusUnsupportedMediaType
+			return nil, err
+		}
+		p.writeCache(path, out)
+		return out, nil
+	})
+	// 合并到其他请求时 status 未被设置，按上游错误处理
+	if err != nil && status == http.StatusOK {
+		status = http.StatusBadGateway
+	}
+	return data, status, err
+}
+
+// original 返回原图数据，优先读取磁盘缓存
+func (p *ImageProxy) original(rawURL, referer string) ([]byte, error) {
+	path := p.cachePath(rawURL, ".src")
+	if data, ok := p.readCache(path); ok {
+		return data, nil
+	}
+	return p.shared("src|"+rawURL, func() ([]byte, error) {
+		data, err := p.fetch(rawURL, referer)
+		if err != nil {
+			return nil, err
+		}
+		p.writeCache(path, data)
+		return data, nil
+	})
+}
+
+// fetch 携带 Referer 请求上游图片
+func (p *ImageProxy) fetch(rawURL, referer string) ([]byte, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+	req.Header.Set("Accept", "image/webp,image/jpeg,image/png,image/*;q=0.8")
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求图片失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("图片服务器返回状态码 %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.maxSource+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %v", err)
+	}
+	if int64(len(data)) > p.maxSource {
+		return nil, fmt.Errorf("图片超过 %d MB 上限", p.maxSource>>20)
+	}
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return nil, fmt.Errorf("上游返回的不是图片")
+	}
+	return data, nil
+}
+
+// shared 合并相同 key 的并发请求
+func (p *ImageProxy) shared(key string, fn func() ([]byte, error)) ([]byte, error) {
+	p.mu.Lock()
+	if call, ok := p.inflight[key]; ok {
+		p.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	p.inflight[key] = call
+	p.mu.Unlock()
+
+	call.value, call.err = fn()
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	p.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// cachePath 返回 key 对应的磁盘缓存文件路径
+func (p *ImageProxy) cachePath(key, ext string) string {
+	sum := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(p.dir, name[:2], name+ext)
+}
+
+// readCache 读取未过期的磁盘缓存
+func (p *ImageProxy) readCache(path string) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > p.cacheTTL {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// writeCache 写入磁盘缓存，先写临时文件再重命名，避免并发读到不完整的文件
+func (p *ImageProxy) writeCache(path string, data []byte) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		imageLog.Warn("⚠️ 图片缓存写入磁盘失败", "error", err)
+	}
+}
+
+// transformImage 按参数缩放并重新编码图片
+// 不需要缩放且原图已是目标格式时原样返回，避免重复压缩
+func transformImage(src []byte, v imageVariant) ([]byte, error) {
+	cfg, srcFormat, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("无法识别的图片格式: %v", err)
+	}
+	// 图片头声明的尺寸决定解码时的内存占用，超出像素上限的直接拒绝
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 超出上限", cfg.Width, cfg.Height)
+	}
+	format := v.format
+	if format == "" {
+		format = "jpeg"
+		if srcFormat == "png" {
+			format = "png"
+		}
+	}
+	resize := v.width > 0 && v.width < cfg.Width
+	if !resize && format == srcFormat {
+		return src, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	if resize || format == "jpeg" {
+		width, height := cfg.Width, cfg.Height
+		if resize {
+			width = v.width
+			height = cfg.Height * v.width / cfg.Width
+			if height < 1 {
+				height = 1
+			}
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		// JPEG 不支持透明通道，透明区域铺白色背景
+		if format == "jpeg" {
+			draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		}
+		if resize {
+			draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+		} else {
+			draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+		}
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: v.quality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码图片失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// etagMatches 判断 If-None-Match 是否包含指定 ETag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package components
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testImage 生成指定尺寸、格式的测试图片，transparent 为 true 时生成全透明的 PNG
+func testImage(t *testing.T, format string, width, height int, transparent bool) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if !transparent {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// oversizedPNG 生成一个 IHDR 声明为 width×height 的 PNG，实际像素数据很小
+func oversizedPNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	data := testImage(t, "png", 1, 1, false)
+	// 8 字节签名 + 4 字节长度 + "IHDR"，之后是宽、高，CRC 覆盖类型和数据共 17 字节
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestTransformImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         []byte
+		variant     imageVariant
+		passthrough bool // 期望原样返回
+		wantFormat  string
+		wantWidth   int
+		wantHeight  int
+		wantErr     bool
+	}{
+		{"JPEG 不缩放原样返回", testImage(t, "jpeg", 100, 50, false), imageVariant{quality: 80}, true, "jpeg", 100, 50, false},
+		{"PNG 目标宽度不小于原图时原样返回", testImage(t, "png", 100, 50, false), imageVariant{width: 200, quality: 80}, true, "png", 100, 50, false},
+		{"JPEG 按宽度等比缩小", testImage(t, "jpeg", 100, 50, false), imageVariant{width: 40, quality: 80}, false, "jpeg", 40, 20, false},
+		{"PNG 缩小后保持 PNG", testImage(t, "png", 100, 50, false), imageVariant{width: 50, quality: 80}, false, "png", 50, 25, false},
+		{"PNG 转 JPEG", testImage(t, "png", 100, 50, false), imageVariant{format: "jpeg", quality: 80}, false, "jpeg", 100, 50, false},
+		{"JPEG 转 PNG", testImage(t, "jpeg", 100, 50, false), imageVariant{format: "png", quality: 80}, false, "png", 100, 50, false},
+		{"GIF 默认转为 JPEG", testImage(t, "gif", 30, 20, false), imageVariant{quality: 80}, false, "jpeg", 30, 20, false},
+		{"缩放后高度至少为 1", testImage(t, "png", 100, 1, false), imageVariant{width: 10, quality: 80}, false, "png", 10, 1, false},
+		{"无法识别的格式", []byte("not an image"), imageVariant{quality: 80}, false, "", 0, 0, true},
+		{"超出像素上限", oversizedPNG(t, 60000, 60000), imageVariant{width: 100, quality: 80}, false, "", 0, 0, true},
+		{"单边超长", oversizedPNG(t, 1<<30, 1), imageVariant{quality: 80}, false, "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := transformImage(tt.src, tt.variant)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("transformImage() 应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bytes.Equal(out, tt.src); got != tt.passthrough {
+				t.Errorf("原样返回 = %v, want %v", got, tt.passthrough)
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.wantFormat || cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight {
+				t.Errorf("输出 %s %dx%d, want %s %dx%d", format, cfg.Width, cfg.Height,
+					tt.wantFormat, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestTransformImagePixelLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height uint32
+		wantLimit     bool
+	}{
+		{"等于 4000 万像素", 8000, 5000, false},
+		{"超过 4000 万像素", 6400, 6400, true},
+	}
+	for _, tt := range tests {
+		// 不缩放的 PNG 未超出上限时原样返回，不会解码
+		_, err := transformImage(oversizedPNG(t, tt.width, tt.height), imageVariant{quality: 80})
+		if got := err != nil && strings.Contains(err.Error(), "超出上限"); got != tt.wantLimit {
+			t.Errorf("%s: err = %v, 是否超出像素上限 = %v, want %v", tt.name, err, got, tt.wantLimit)
+		}
+	}
+}
+
+func TestTransformImageTransparentToJPEG(t *testing.T) {
+	out, err := transformImage(testImage(t, "png", 8, 8, true), imageVariant{format: "jpeg", quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// JPEG 没有透明通道，透明区域应为白色
+	r, g, b, _ := img.At(4, 4).RGBA()
+	if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("透明区域颜色 = (%d, %d, %d), want 白色", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"x", "abc"`, true},
+		{"*", true},
+		{`"x"`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MacCMS 检测项名称
+const (
+	CheckProtocol  = "protocol"
+	CheckSample    = "sample_search"
+	CheckPlayProbe = "play_probe"
+)
+
+// ValidateOptions MacCMS 资源检测选项
+type ValidateOptions struct {
+	Timeout       time.Duration
+	SampleKeyword string // 为空时使用最新列表作为样本
+	ProbePlayURL  bool   // 是否探测一个播放地址（m3u8）
+}
+
+// VerdictCheck 单个检测项结果
+type VerdictCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// SourceVerdict MacCMS 资源检测结论
+type SourceVerdict struct {
+	Valid      bool           `json:"valid"`
+	Reasons    []string       `json:"reasons,omitempty"`
+	Checks     []VerdictCheck `json:"checks"`
+	LatencyMs  int64          `json:"latency_ms"`
+	HTTPStatus int            `json:"http_status,omitempty"`
+	ErrorClass string         `json:"error_class,omitempty"`
+	Sample     interface{}    `json:"-"`
+}
+
+// pass 记录通过的检测项
+func (v *SourceVerdict) pass(name, detail string) {
+	v.Checks = append(v.Checks, VerdictCheck{Name: name, Passed: true, Detail: detail})
+}
+
+// fail 记录失败的检测项，第一个失败项的错误分类作为整体分类
+func (v *SourceVerdict) fail(name, class, reason string) {
+	v.Checks = append(v.Checks, VerdictCheck{Name: name, Detail: reason})
+	v.Reasons = append(v.Reasons, reason)
+	if v.ErrorClass == "" {
+		v.ErrorClass = class
+	}
+}
+
+// skip 记录跳过的检测项
+func (v *SourceVerdict) skip(name, detail string) {
+	v.Checks = append(v.Checks, VerdictCheck{Name: name, Passed: true, Skipped: true, Detail: detail})
+}
+
+// Summary 返回检测结论的简短描述
+func (v *SourceVerdict) Summary() string {
+	if v.Valid {
+		return "ok"
+	}
+	return strings.Join(v.Reasons, "; ")
+}
+
+// macCMSResponse MacCMS 接口响应中用于校验的字段
+type macCMSResponse struct {
+	Code      json.RawMessage          `json:"code"`
+	PageCount json.RawMessage          `json:"pagecount"`
+	List      []map[string]interface{} `json:"list"`
+}
+
+// ValidateMacCMSSource 在协议层面检测 MacCMS 资源：
+// 校验 code/list/pagecount 字段，确认样本列表中存在带播放地址的视频，并可选探测一个 m3u8
+func ValidateMacCMSSource(api string, opts ValidateOptions) *SourceVerdict {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 15 * time.Second
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+	verdict := &SourceVerdict{}
+
+	// 1. 协议检测
+	start := time.Now()
+	status, body, err := fetchMacCMS(client, api, nil)
+	verdict.LatencyMs = time.Since(start).Milliseconds()
+	verdict.HTTPStatus = status
+	if err != nil {
+		verdict.fail(CheckProtocol, classifyCheckError(err), err.Error())
+		return verdict
+	}
+	if status != http.StatusOK {
+		class := "http_4xx"
+		if status >= 500 {
+			class = "http_5xx"
+		}
+		verdict.fail(CheckProtocol, class, fmt.Sprintf("HTTP %d", status))
+		return verdict
+	}
+
+	var raw interface{}
+	var resp macCMSResponse
+	if json.Unmarshal(body, &raw) != nil || json.Unmarshal(body, &resp) != nil {
+		verdict.fail(CheckProtocol, "invalid_json", "响应不是有效的JSON")
+		return verdict
+	}
+	verdict.Sample = raw
+
+	var missing []string
+	if len(resp.Code) == 0 {
+		missing = append(missing, "code")
+	}
+	if resp.List == nil {
+		missing = append(missing, "list")
+	}
+	if len(resp.PageCount) == 0 {
+		missing = append(missing, "pagecount")
+	}
+	if len(missing) > 0 {
+		verdict.fail(CheckProtocol, "missing_fields", "缺少MacCMS字段: "+strings.Join(missing, ", "))
+		return verdict
+	}
+	if code := strings.Trim(string(resp.Code), `"`); code != "1" {
+		verdict.fail(CheckProtocol, "bad_code", "code 不为 1: "+code)
+		return verdict
+	}
+	verdict.pass(CheckProtocol, fmt.Sprintf("pagecount=%s", strings.Trim(string(resp.PageCount), `"`)))
+
+	// 2. 样本检测：必须返回带播放地址的视频
+	params := url.Values{}
+	params.Set("ac", "videolist")
+	if opts.SampleKeyword != "" {
+		params.Set("wd", opts.SampleKeyword)
+	} else {
+		params.Set("pg", "1")
+	}
+	status, body, err = fetchMacCMS(client, api, params)
+	if err != nil {
+		verdict.fail(CheckSample, classifyCheckError(err), "样本请求失败: "+err.Error())
+		return verdict
+	}
+	if status != http.StatusOK {
+		verdict.fail(CheckSample, "http_error", fmt.Sprintf("样本请求 HTTP %d", status))
+		return verdict
+	}
+	var sample macCMSResponse
+	if err := json.Unmarshal(body, &sample); err != nil {
+		verdict.fail(CheckSample, "invalid_json", "样本响应不是有效的JSON")
+		return verdict
+	}
+	if len(sample.List) == 0 {
+		verdict.fail(CheckSample, "empty_list", "样本列表为空")
+		return verdict
+	}
+	var sampleRaw interface{}
+	if json.Unmarshal(body, &sampleRaw) == nil {
+		verdict.Sample = sampleRaw
+	}
+
+	playable := 0
+	playURL := ""
+	for _, item := range sample.List {
+		playField := getString(item, "vod_play_url")
+		if strings.TrimSpace(playField) == "" {
+			continue
+		}
+		playable++
+		if playURL == "" {
+			playURL = firstM3U8URL(playField)
+		}
+	}
+	if playable == 0 {
+		verdict.fail(CheckSample, "no_play_url", "样本视频均缺少 vod_play_url")
+		return verdict
+	}
+	verdict.pass(CheckSample, fmt.Sprintf("%d/%d 个视频包含播放地址", playable, len(sample.List)))
+
+	// 3. 可选：探测播放地址
+	if !opts.ProbePlayURL {
+		verdict.Valid = true
+		return verdict
+	}
+	if playURL == "" {
+		verdict.skip(CheckPlayProbe, "样本中没有 m3u8 播放地址")
+		verdict.Valid = true
+		return verdict
+	}
+	if reason, class := probeM3U8(client, playURL); reason != "" {
+		verdict.fail(CheckPlayProbe, class, reason)
+		return verdict
+	}
+	verdict.pass(CheckPlayProbe, playURL)
+	verdict.Valid = true
+	return verdict
+}
+
+// fetchMacCMS 请求 MacCMS 接口，params 会合并到接口原有的查询参数中
+func fetchMacCMS(client *http.Client, api string, params url.Values) (int, []byte, error) {
+	u, err := url.Parse(api)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(params) > 0 {
+		q := u.Query()
+		for k, v := range params {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36")
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// firstM3U8URL 从 vod_play_url 中提取第一个 m3u8 地址
+// 格式: 线路1第1集$url#第2集$url$$$线路2第1集$url...
+func firstM3U8URL(playField string) string {
+	for _, group := range strings.Split(playField, "$$$") {
+		for _, episode := range strings.Split(group, "#") {
+			u := episode
+			if i := strings.LastIndex(episode, "$"); i >= 0 {
+				u = episode[i+1:]
+			}
+			u = strings.TrimSpace(u)
+			if strings.HasPrefix(u, "http") && strings.Contains(strings.ToLower(u), ".m3u8") {
+				return u
+			}
+		}
+	}
+	return ""
+}
+
+// probeM3U8 探测 m3u8 播放地址是否可访问且可解析，失败时返回原因和分类
+func probeM3U8(client *http.Client, playURL string) (string, string) {
+	resp, err := client.Get(playURL)
+	if err != nil {
+		return "播放地址请求失败: " + err.Error(), classifyCheckError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("播放地址 HTTP %d", resp.StatusCode), "play_http_error"
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256<<10))
+	if err != nil {
+		return "读取播放列表失败: " + err.Error(), "play_read_error"
+	}
+	content := strings.TrimSpace(strings.TrimPrefix(string(body), "\ufeff"))
+	if !strings.HasPrefix(content, "#EXTM3U") {
+		return "播放地址不是有效的 m3u8", "play_invalid_m3u8"
+	}
+	if !strings.Contains(content, "#EXTINF") && !strings.Contains(content, "#EXT-X-STREAM-INF") {
+		return "m3u8 中没有分片或子播放列表", "play_invalid_m3u8"
+	}
+	return "", ""
+}
@@ -0,0 +1,145 @@
+package components
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 模拟 MacCMS 接口的默认响应，{{host}} 替换为测试服务器地址
+const (
+	testMacCMSProtocol = `{"code":1,"msg":"数据列表","page":1,"pagecount":10,"list":[]}`
+	testMacCMSList     = `{"code":1,"pagecount":10,"list":[{"vod_name":"测试视频","vod_play_url":"第1集$http://{{host}}/play/index.m3u8"}]}`
+	testM3U8           = "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nseg-1.ts\n#EXT-X-ENDLIST\n"
+)
+
+// fakeMacCMS 模拟的 MacCMS 资源
+type fakeMacCMS struct {
+	status   int    // 协议检测的状态码，0 表示 200
+	protocol string // 协议检测响应，为空时使用 testMacCMSProtocol
+	list     string // 样本列表响应，为空时使用 testMacCMSList
+	m3u8     string // 播放地址响应，为空时使用 testM3U8
+}
+
+// newFakeMacCMS 启动模拟的 MacCMS 资源，返回接口地址
+func newFakeMacCMS(t *testing.T, f fakeMacCMS) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/play/") {
+			body := f.m3u8
+			if body == "" {
+				body = testM3U8
+			}
+			if body == "404" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, body)
+			return
+		}
+		if r.URL.Query().Get("ac") == "videolist" {
+			body := f.list
+			if body == "" {
+				body = testMacCMSList
+			}
+			fmt.Fprint(w, strings.ReplaceAll(body, "{{host}}", r.Host))
+			return
+		}
+		if f.status != 0 {
+			w.WriteHeader(f.status)
+		}
+		body := f.protocol
+		if body == "" {
+			body = testMacCMSProtocol
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/api.php/provide/vod/"
+}
+
+func TestValidateMacCMSSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    fakeMacCMS
+		probe     bool
+		wantValid bool
+		wantClass string
+		wantCheck []string // 期望的检测项，跳过的检测项以 "~" 开头
+	}{
+		{"有效资源", fakeMacCMS{}, false, true, "", []string{CheckProtocol, CheckSample}},
+		{"探测播放地址", fakeMacCMS{}, true, true, "", []string{CheckProtocol, CheckSample, CheckPlayProbe}},
+		{"HTTP 4xx", fakeMacCMS{status: http.StatusNotFound}, false, false, "http_4xx", nil},
+		{"HTTP 5xx", fakeMacCMS{status: http.StatusBadGateway}, false, false, "http_5xx", nil},
+		{"无效 JSON", fakeMacCMS{protocol: "<html></html>"}, false, false, "invalid_json", nil},
+		{"缺少字段", fakeMacCMS{protocol: `{"code":1}`}, false, false, "missing_fields", nil},
+		{"code 不为 1", fakeMacCMS{protocol: `{"code":0,"pagecount":1,"list":[]}`}, false, false, "bad_code", nil},
+		{"字符串形式的 code", fakeMacCMS{protocol: `{"code":"1","pagecount":"1","list":[]}`}, false, true, "", nil},
+		{"样本列表为空", fakeMacCMS{list: `{"code":1,"pagecount":0,"list":[]}`}, false, false, "empty_list", nil},
+		{"样本缺少播放地址", fakeMacCMS{list: `{"code":1,"list":[{"vod_name":"a","vod_play_url":" "}]}`}, false, false, "no_play_url", nil},
+		{"样本中没有 m3u8 时跳过探测",
+			fakeMacCMS{list: `{"code":1,"list":[{"vod_name":"a","vod_play_url":"第1集$http://{{host}}/play/1.mp4"}]}`},
+			true, true, "", []string{CheckProtocol, CheckSample, "~" + CheckPlayProbe}},
+		{"播放地址不可访问", fakeMacCMS{m3u8: "404"}, true, false, "play_http_error", nil},
+		{"播放地址不是 m3u8", fakeMacCMS{m3u8: "<html></html>"}, true, false, "play_invalid_m3u8", nil},
+		{"m3u8 没有分片", fakeMacCMS{m3u8: "#EXTM3U\n#EXT-X-ENDLIST\n"}, true, false, "play_invalid_m3u8", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeMacCMS(t, tt.source)
+			v := ValidateMacCMSSource(api, ValidateOptions{Timeout: 5 * time.Second, ProbePlayURL: tt.probe})
+			if v.Valid != tt.wantValid || v.ErrorClass != tt.wantClass {
+				t.Fatalf("verdict = valid %v, class %q (%s), want valid %v, class %q",
+					v.Valid, v.ErrorClass, v.Summary(), tt.wantValid, tt.wantClass)
+			}
+			if v.HTTPStatus == 0 {
+				t.Error("应记录协议检测的 HTTP 状态码")
+			}
+			if tt.wantCheck == nil {
+				return
+			}
+			var checks []string
+			for _, c := range v.Checks {
+				name := c.Name
+				if c.Skipped {
+					name = "~" + name
+				}
+				checks = append(checks, name)
+			}
+			if strings.Join(checks, ",") != strings.Join(tt.wantCheck, ",") {
+				t.Errorf("checks = %v, want %v", checks, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestValidateMacCMSSourceUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	api := srv.URL
+	srv.Close()
+	v := ValidateMacCMSSource(api, ValidateOptions{Timeout: time.Second})
+	if v.Valid || v.ErrorClass != "connection_refused" || v.HTTPStatus != 0 {
+		t.Errorf("verdict = valid %v, class %q, status %d", v.Valid, v.ErrorClass, v.HTTPStatus)
+	}
+}
+
+func TestFirstM3U8URL(t *testing.T) {
+	tests := []struct {
+		play string
+		want string
+	}{
+		{"第1集$http://a.com/1.m3u8#第2集$http://a.com/2.m3u8", "http://a.com/1.m3u8"},
+		{"第1集$http://a.com/1.mp4$$$第1集$https://b.com/1.M3U8", "https://b.com/1.M3U8"},
+		{"http://a.com/index.m3u8", "http://a.com/index.m3u8"},
+		{"第1集$ftp://a.com/1.m3u8", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := firstM3U8URL(tt.play); got != tt.want {
+			t.Errorf("firstM3U8URL(%q) = %q, want %q", tt.play, got, tt.want)
+		}
+	}
+}
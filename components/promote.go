@@ -0,0 +1,274 @@
+package components
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// OriginScorpio 由 scorpio 资源提升而来的视频源
+const OriginScorpio = "scorpio"
+
+// ScorpioPromoter 将 scorpio 候选资源提升为可用视频源
+type ScorpioPromoter struct {
+	sources *SourcesConfig
+	scorpio *ScorpioManager
+}
+
+// NewScorpioPromoter 创建新的资源提升器
+func NewScorpioPromoter(sources *SourcesConfig, scorpio *ScorpioManager) *ScorpioPromoter {
+	return &ScorpioPromoter{
+		sources: sources,
+		scorpio: scorpio,
+	}
+}
+
+// ScorpioSourceCode 根据资源接口地址生成稳定的视频源代码
+func ScorpioSourceCode(api string) string {
+	sum := sha1.Sum([]byte(api))
+	return "sc_" + hex.EncodeToString(sum[:4])
+}
+
+// findScorpio 根据名称查找 scorpio 资源
+func (p *ScorpioPromoter) findScorpio(name string) (ScorpioSource, bool) {
+	for _, s := range p.scorpio.Sources() {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return ScorpioSource{}, false
+}
+
+// adultSourceKeywords 名称或域名中出现时视为成人资源的关键词
+var adultSourceKeywords = []string{
+	"成人", "福利", "伦理", "色情", "情色", "黄色", "激情", "午夜", "宅男", "撸",
+	"18+", "av", "jav", "porn", "xxx", "sex", "adult", "hentai",
+}
+
+// looksAdult 按名称和接口域名判断 scorpio 资源是否为成人资源
+// 英文关键词按单词或域名片段匹配，避免误伤（如 "java"、"travel"）
+func looksAdult(name, api string) bool {
+	text := strings.ToLower(name)
+	if u, err := url.Parse(api); err == nil {
+		text += " " + strings.ToLower(u.Hostname())
+	}
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return r < 0x80 && !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+'
+	})
+	for _, k := range adultSourceKeywords {
+		if k[0] >= 0x80 {
+			if strings.Contains(text, k) {
+				return true
+			}
+			continue
+		}
+		for _, w := range words {
+			// 较长的关键词同时匹配前缀（如 "pornhub"），短关键词只匹配完整单词
+			if w == k || (len(k) >= 4 && strings.HasPrefix(w, k)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Promote 将指定名称的 scorpio 资源提升为视频源，只允许提升检测可用的资源
+// adult 为 nil 时按名称和域名判断是否为成人资源，成人资源受内容过滤影响
+func (p *ScorpioPromoter) Promote(name string, adult *bool) (*VideoSource, error) {
+	s, ok := p.findScorpio(name)
+	if !ok {
+		return nil, fmt.Errorf("scorpio 资源 %s 不存在", name)
+	}
+	if s.IsValid == nil || !*s.IsValid {
+		return nil, fmt.Errorf("scorpio 资源 %s 未通过检测，无法提升", name)
+	}
+
+	source := VideoSource{
+		Code:    ScorpioSourceCode(s.API),
+		Name:    s.Name,
+		URL:     s.API,
+		Adult:   looksAdult(s.Name, s.API),
+		Enabled: true,
+		Origin:  OriginScorpio,
+	}
+	if adult != nil {
+		source.Adult = *adult
+	}
+	if err := p.sources.AddSource(source); err != nil {
+		return nil, err
+	}
+	scorpioLog.Info("⬆️ scorpio 资源已提升为视频源", "name", s.Name, "source", source.Code, "adult", source.Adult)
+	return &source, nil
+}
+
+// Demote 移除由指定名称的 scorpio 资源提升而来的视频源
+func (p *ScorpioPromoter) Demote(name string) error {
+	s, ok := p.findScorpio(name)
+	if !ok {
+		return fmt.Errorf("scorpio 资源 %s 不存在", name)
+	}
+	code := ScorpioSourceCode(s.API)
+	source := p.sources.GetSourceByCode(code)
+	if source == nil || source.Origin != OriginScorpio {
+		return fmt.Errorf("scorpio 资源 %s 未被提升", name)
+	}
+	if err := p.sources.RemoveSource(code); err != nil {
+		return err
+	}
+	scorpioLog.Info("⬇️ 视频源已降级", "source", code, "name", s.Name)
+	return nil
+}
+
+// AutoPromote 提升延迟最低的前 top 个可用资源
+// replace 为 true 时，同时降级不在前 top 名中的已提升资源
+func (p *ScorpioPromoter) AutoPromote(top int, replace bool) ([]VideoSource, error) {
+	if top <= 0 {
+		return nil, fmt.Errorf("top 必须大于 0")
+	}
+
+	type candidate struct {
+		source  ScorpioSource
+		latency int64
+	}
+	var candidates []candidate
+	for _, s := range p.scorpio.Sources() {
+		if s.IsValid == nil || !*s.IsValid {
+			continue
+		}
+		latency := s.LatencyMs
+		if stats := p.scorpio.history.Stats(s.Name); stats != nil && stats.P50LatencyMs > 0 {
+			latency = stats.P50LatencyMs
+		}
+		candidates = append(candidates, candidate{source: s, latency: latency})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].latency < candidates[j].latency
+	})
+	if len(candidates) > top {
+		candidates = candidates[:top]
+	}
+
+	keep := make(map[string]bool)
+	var promoted []VideoSource
+	for _, c := range candidates {
+		code := ScorpioSourceCode(c.source.API)
+		keep[code] = true
+		if p.sources.GetSourceByCode(code) != nil {
+			continue
+		}
+		source, err := p.Promote(c.source.Name, nil)
+		if err != nil {
+			scorpioLog.Warn("⚠️ 自动提升 scorpio 资源失败", "name", c.source.Name, "error", err)
+			continue
+		}
+		promoted = append(promoted, *source)
+	}
+
+	if replace {
+		for _, source := range p.sources.GetSources() {
+			if source.Origin == OriginScorpio && !keep[source.Code] {
+				if err := p.sources.RemoveSource(source.Code); err != nil {
+					scorpioLog.Warn("⚠️ 降级视频源失败", "source", source.Code, "error", err)
+					continue
+				}
+				scorpioLog.Info("⬇️ 视频源不在前列，已降级", "source", source.Code, "name", source.Name, "top", top)
+			}
+		}
+	}
+	return promoted, nil
+}
+
+// promoteRequest 提升/降级接口的请求体
+type promoteRequest struct {
+	Name    string `json:"name"`
+	Top     int    `json:"top"`
+	Replace bool   `json:"replace"`
+	Adult   *bool  `json:"adult"` // 提升单个资源时指定是否为成人资源，不传时自动判断
+}
+
+// decodePromoteRequest 解析请求体，失败时直接写出 400 响应
+func decodePromoteRequest(w http.ResponseWriter, r *http.Request) (*promoteRequest, bool) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	var req promoteRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writePromoteError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	return &req, true
+}
+
+// writePromoteError 写出错误响应
+func writePromoteError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+		"data":    nil,
+	})
+}
+
+// HandlePromoteAPI 处理 /api/admin/scorpio/promote 接口
+func (p *ScorpioPromoter) HandlePromoteAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromoteRequest(w, r)
+	if !ok {
+		return
+	}
+	source, err := p.Promote(req.Name, req.Adult)
+	if err != nil {
+		writePromoteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    source,
+	})
+	scorpioLog.DebugContext(r.Context(), "✅ /api/admin/scorpio/promote 请求")
+}
+
+// HandleDemoteAPI 处理 /api/admin/scorpio/demote 接口
+func (p *ScorpioPromoter) HandleDemoteAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromoteRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := p.Demote(req.Name); err != nil {
+		writePromoteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+	scorpioLog.DebugContext(r.Context(), "✅ /api/admin/scorpio/demote 请求")
+}
+
+// HandleAutoPromoteAPI 处理 /api/admin/scorpio/auto_promote 接口
+func (p *ScorpioPromoter) HandleAutoPromoteAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromoteRequest(w, r)
+	if !ok {
+		return
+	}
+	promoted, err := p.AutoPromote(req.Top, req.Replace)
+	if err != nil {
+		writePromoteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    promoted,
+		"count":   len(promoted),
+	})
+	scorpioLog.DebugContext(r.Context(), "✅ /api/admin/scorpio/auto_promote 请求")
+}
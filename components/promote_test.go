@@ -0,0 +1,187 @@
+package components
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestScorpioSourceCode(t *testing.T) {
+	api := "https://api.example.com/api.php/provide/vod/"
+	sum := sha1.Sum([]byte(api))
+	code := ScorpioSourceCode(api)
+	if code != "sc_"+hex.EncodeToString(sum[:4]) {
+		t.Errorf("ScorpioSourceCode() = %q", code)
+	}
+	if !regexp.MustCompile(`^sc_[0-9a-f]{8}$`).MatchString(code) || !sourceCodePattern.MatchString(code) {
+		t.Errorf("ScorpioSourceCode() = %q, 不是有效的视频源代码", code)
+	}
+	if ScorpioSourceCode(api) != code {
+		t.Error("相同地址应生成相同的代码")
+	}
+	if ScorpioSourceCode(api+"?x=1") == code {
+		t.Error("不同地址应生成不同的代码")
+	}
+}
+
+func TestLooksAdult(t *testing.T) {
+	tests := []struct {
+		name string
+		api  string
+		want bool
+	}{
+		{"福利资源", "https://a.example.com/api.php", true},
+		{"普通资源", "https://sexy-movies.example.com/api.php", false},
+		{"普通资源", "https://av.example.com/api.php", true},
+		{"普通资源", "https://pornhub-api.example.com/", true},
+		{"XXX Videos", "https://a.example.com/", true},
+		{"18+ 专区", "https://a.example.com/", true},
+		{"Java 影视", "https://java.example.com/", false},
+		{"Travel 旅游", "https://travel.example.com/", false},
+		{"Savvy 资源", "https://savvy.example.com/", false},
+		{"量子资源", "https://cj.lziapi.com/api.php/provide/vod/", false},
+		{"普通资源", "://bad url", false},
+	}
+	for _, tt := range tests {
+		if got := looksAdult(tt.name, tt.api); got != tt.want {
+			t.Errorf("looksAdult(%q, %q) = %v, want %v", tt.name, tt.api, got, tt.want)
+		}
+	}
+}
+
+// newTestPromoter 创建使用临时文件保存的资源提升器
+func newTestPromoter(t *testing.T, sources ...*ScorpioSource) (*ScorpioPromoter, *SourcesConfig) {
+	t.Helper()
+	sc := NewSourcesConfig()
+	if err := sc.LoadLocalSources(filepath.Join(t.TempDir(), "sources_local.json")); err != nil {
+		t.Fatal(err)
+	}
+	return NewScorpioPromoter(sc, newTestScorpioManager(t, sources...)), sc
+}
+
+func TestPromoteDemote(t *testing.T) {
+	valid, invalid := true, false
+	p, sc := newTestPromoter(t,
+		&ScorpioSource{Name: "好资源", API: "https://good.example.com/", IsValid: &valid},
+		&ScorpioSource{Name: "福利资源", API: "https://adult.example.com/", IsValid: &valid},
+		&ScorpioSource{Name: "失效资源", API: "https://bad.example.com/", IsValid: &invalid},
+		&ScorpioSource{Name: "未检测", API: "https://new.example.com/"},
+	)
+
+	source, err := p.Promote("好资源", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := ScorpioSourceCode("https://good.example.com/")
+	if source.Code != code || source.Origin != OriginScorpio || !source.Enabled || source.Adult {
+		t.Errorf("提升后的视频源 = %+v", source)
+	}
+	if got := sc.GetSourceByCode(code); got == nil || got.URL != "https://good.example.com/" {
+		t.Errorf("GetSourceByCode() = %+v", got)
+	}
+	if _, err := p.Promote("好资源", nil); err == nil {
+		t.Error("重复提升应返回错误")
+	}
+
+	if source, err := p.Promote("福利资源", nil); err != nil || !source.Adult {
+		t.Errorf("成人资源应自动标记: %+v, %v", source, err)
+	}
+	if err := p.Demote("福利资源"); err != nil {
+		t.Fatal(err)
+	}
+	notAdult := false
+	if source, err := p.Promote("福利资源", &notAdult); err != nil || source.Adult {
+		t.Errorf("显式指定 adult 时应覆盖自动判断: %+v, %v", source, err)
+	}
+
+	for _, name := range []string{"失效资源", "未检测", "不存在"} {
+		if _, err := p.Promote(name, nil); err == nil {
+			t.Errorf("Promote(%q) 应返回错误", name)
+		}
+	}
+
+	if err := p.Demote("好资源"); err != nil {
+		t.Fatal(err)
+	}
+	if sc.GetSourceByCode(code) != nil {
+		t.Error("降级后视频源应被移除")
+	}
+	if err := p.Demote("好资源"); err == nil {
+		t.Error("未提升的资源降级应返回错误")
+	}
+
+	// 降级后可以再次提升
+	if _, err := p.Promote("好资源", nil); err != nil {
+		t.Errorf("再次提升失败: %v", err)
+	}
+}
+
+func TestAutoPromote(t *testing.T) {
+	valid, invalid := true, false
+	p, sc := newTestPromoter(t,
+		&ScorpioSource{Name: "慢", API: "https://slow.example.com/", IsValid: &valid, LatencyMs: 100},
+		&ScorpioSource{Name: "快", API: "https://fast.example.com/", IsValid: &valid, LatencyMs: 200},
+		&ScorpioSource{Name: "中", API: "https://mid.example.com/", IsValid: &valid, LatencyMs: 300},
+		&ScorpioSource{Name: "失效", API: "https://bad.example.com/", IsValid: &invalid, LatencyMs: 1},
+	)
+	// 有检测历史时按 P50 延迟排序
+	p.scorpio.history.Add("慢", CheckRecord{Time: 1, Success: true, LatencyMs: 900})
+
+	codes := func() string {
+		var list []string
+		for _, s := range sc.GetSources() {
+			list = append(list, s.Name)
+		}
+		return strings.Join(list, ",")
+	}
+
+	if _, err := p.AutoPromote(0, false); err == nil {
+		t.Error("top 为 0 时应返回错误")
+	}
+	promoted, err := p.AutoPromote(2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 2 || codes() != "快,中" {
+		t.Errorf("提升结果 = %s", codes())
+	}
+
+	// 新的排名中 "中" 掉出前 1 名，replace 时降级
+	if promoted, err := p.AutoPromote(1, true); err != nil || len(promoted) != 0 || codes() != "快" {
+		t.Errorf("替换后的视频源 = %s, promoted = %v, err = %v", codes(), promoted, err)
+	}
+}
+
+func TestPromoteAPI(t *testing.T) {
+	valid := true
+	p, _ := newTestPromoter(t, &ScorpioSource{Name: "a", API: "https://a.example.com/", IsValid: &valid})
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		want    int
+	}{
+		{"提升", p.HandlePromoteAPI, "POST", `{"name":"a"}`, http.StatusOK},
+		{"重复提升", p.HandlePromoteAPI, "POST", `{"name":"a"}`, http.StatusBadRequest},
+		{"请求体无效", p.HandlePromoteAPI, "POST", `{`, http.StatusBadRequest},
+		{"方法不允许", p.HandlePromoteAPI, "GET", ``, http.StatusMethodNotAllowed},
+		{"降级", p.HandleDemoteAPI, "POST", `{"name":"a"}`, http.StatusOK},
+		{"降级不存在的资源", p.HandleDemoteAPI, "POST", `{"name":"b"}`, http.StatusBadRequest},
+		{"自动提升", p.HandleAutoPromoteAPI, "POST", `{"top":1}`, http.StatusOK},
+		{"自动提升 top 无效", p.HandleAutoPromoteAPI, "POST", `{"top":-1}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest(tt.method, "/api/admin/scorpio", strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package components
 
 import (
+	"bytes"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -12,11 +14,45 @@ import (
 	"vastproxy-go/utils"
 )
 
+// proxyLog 代理服务日志
+var proxyLog = utils.Logger("proxy")
+
+// proxyClient 代理请求使用的客户端
+// 只限制连接与等待响应头的时间，不设置总超时：限速后的长视频流传输时间可能远超固定时长，由请求上下文负责取消
+var proxyClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   8,
+	},
+}
+
+// proxyForwardHeaders 代理时允许转发给上游的请求头（另外放行所有 Accept 开头的请求头）
+var proxyForwardHeaders = map[string]bool{
+	"range":             true,
+	"if-range":          true,
+	"user-agent":        true,
+	"if-none-match":     true,
+	"if-modified-since": true,
+}
+
+// forwardableHeader 判断请求头是否可以转发给上游
+func forwardableHeader(name string) bool {
+	name = strings.ToLower(name)
+	return proxyForwardHeaders[name] || (strings.HasPrefix(name, "accept") && name != "accept-encoding")
+}
+
 // ProxyHandler 处理代理请求
 func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface{}) {
 	startTime := time.Now()
-	fullQuery := r.URL.RawQuery
-	log.Printf("🔍 完整查询字符串: %s [IP:%s]", fullQuery, utils.GetRequestIP(r))
+	ctx := r.Context()
+	proxyLog.DebugContext(ctx, "🔍 代理请求", "query", r.URL.RawQuery)
 
 	urlParam := r.URL.Query().Get("url")
 	if urlParam == "" {
@@ -27,34 +63,37 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 
 	decodedURL, err := url.QueryUnescape(urlParam)
 	if err != nil {
-		log.Printf("❌ URL解码失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		proxyLog.WarnContext(ctx, "❌ URL解码失败", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Invalid URL encoding"))
 		return
 	}
-	log.Printf("🔍 解码后的URL: %s [IP:%s]", decodedURL, utils.GetRequestIP(r))
-	log.Printf("📋 来源IP: %s [IP:%s]", r.RemoteAddr, utils.GetRequestIP(r))
-	log.Printf("🔗 最终请求URL: %s [IP:%s]", decodedURL, utils.GetRequestIP(r))
+	proxyLog.DebugContext(ctx, "🔗 最终请求URL", "url", decodedURL, "remote_addr", r.RemoteAddr)
 
-	// 构建请求
-	req, err := http.NewRequest("GET", decodedURL, nil)
+	// 构建请求，客户端断开时随请求上下文取消
+	req, err := http.NewRequestWithContext(ctx, "GET", decodedURL, nil)
 	if err != nil {
-		log.Printf("❌ 构建请求失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		proxyLog.WarnContext(ctx, "❌ 构建请求失败", "url", decodedURL, "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Invalid target URL"))
 		return
 	}
 
-	// 复制前端请求头，排除Host、Content-Length、Content-Encoding
+	// 只转发白名单内的请求头，Cookie、Authorization 等凭据不能发送给第三方上游
 	for k, v := range r.Header {
-		kLower := strings.ToLower(k)
-		if kLower == "host" || kLower == "content-length" || kLower == "content-encoding" {
+		if !forwardableHeader(k) {
 			continue
 		}
 		for _, vv := range v {
 			req.Header.Add(k, vv)
 		}
 	}
+	// referer 参数指定上游需要的 Referer（如豆瓣图片防盗链），覆盖浏览器自带的 Referer
+	if referer := r.URL.Query().Get("referer"); referer != "" {
+		if u, err := url.Parse(referer); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			req.Header.Set("Referer", referer)
+		}
+	}
 	// 设置 User-Agent
 	if req.Header.Get("User-Agent") == "" {
 		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
@@ -62,12 +101,10 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 	// 强制禁用压缩
 	req.Header.Set("Accept-Encoding", "identity")
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := proxyClient.Do(req)
 	if err != nil {
-		log.Printf("❌ 代理请求失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		utils.SetUpstream(ctx, req.URL, 0)
+		proxyLog.ErrorContext(ctx, "❌ 代理请求失败", "url", decodedURL, "error", err)
 		if os.IsTimeout(err) {
 			w.WriteHeader(http.StatusGatewayTimeout)
 			w.Write([]byte("Request timeout"))
@@ -78,19 +115,24 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 		return
 	}
 	defer resp.Body.Close()
+	utils.SetUpstream(ctx, resp.Request.URL, resp.StatusCode)
 
-	requestTime := time.Since(startTime).Seconds()
-	log.Printf("✅ 目标服务器响应: %d (%.2fs) [IP:%s]", resp.StatusCode, requestTime, utils.GetRequestIP(r))
-	log.Printf("📥 响应头: %+v [IP:%s]", resp.Header, utils.GetRequestIP(r))
-	log.Printf("🔗 最终URL: %s [IP:%s]", resp.Request.URL.String(), utils.GetRequestIP(r))
+	proxyLog.InfoContext(ctx, "✅ 目标服务器响应", "url", decodedURL, "status", resp.StatusCode,
+		"elapsed", time.Since(startTime).Round(time.Millisecond))
 
-	// 读取前1000字节用于日志
-	preview := make([]byte, 1000)
-	n, _ := resp.Body.Read(preview)
-	log.Printf("📄 响应内容预览: %s... [IP:%s]", string(preview[:n]), utils.GetRequestIP(r))
+	// 响应头和内容预览只在 debug 级别记录
+	var bodyReader io.Reader = resp.Body
+	if proxyLog.Enabled(ctx, slog.LevelDebug) {
+		proxyLog.DebugContext(ctx, "📥 响应头", "headers", utils.RedactHeaders(resp.Header), "upstream", resp.Request.URL.String())
 
-	// 重新构造响应体（包含预览和剩余内容）
-	bodyReader := io.MultiReader(strings.NewReader(string(preview[:n])), resp.Body)
+		// 读取前1000字节用于日志
+		preview := make([]byte, 1000)
+		n, _ := resp.Body.Read(preview)
+		proxyLog.DebugContext(ctx, "📄 响应内容预览", "preview", string(preview[:n]))
+
+		// 重新构造响应体（包含预览和剩余内容）
+		bodyReader = io.MultiReader(bytes.NewReader(preview[:n]), resp.Body)
+	}
 
 	// 设置响应头，移除Content-Encoding、Transfer-Encoding、Content-Length
 	for k, v := range resp.Header {
@@ -113,10 +155,20 @@ func ProxyHandler(w http.ResponseWriter, r *http.Request, globalConfig interface
 	}
 
 	w.WriteHeader(resp.StatusCode)
-	// 流式写入响应体
-	_, err = io.Copy(w, bodyReader)
+	// 流式写入响应体，启用带宽限速时按客户端与全局令牌桶限流
+	var dst io.Writer = w
+	if proxyBandwidth != nil {
+		var clientIP string
+		if ip := utils.ResolveRequestIP(r); ip != nil {
+			clientIP = ip.String()
+		}
+		throttled, release := proxyBandwidth.Wrap(r.Context(), w, clientIP)
+		defer release()
+		dst = throttled
+	}
+	_, err = io.Copy(dst, bodyReader)
 	if err != nil {
-		log.Printf("⚠️ 流式传输异常: %v [IP:%s]", err, utils.GetRequestIP(r))
+		proxyLog.WarnContext(ctx, "⚠️ 流式传输异常", "error", err)
 	}
-	log.Printf("✅ 完成流式返回内容 [IP:%s]", utils.GetRequestIP(r))
+	proxyLog.DebugContext(ctx, "✅ 完成流式返回内容")
 }
@@ -0,0 +1,233 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+
+	"golang.org/x/time/rate"
+	"gopkg.in/ini.v1"
+)
+
+// ratelimitLog 接口限流日志
+var ratelimitLog = utils.Logger("ratelimit")
+
+// RateLimitRule 单个路由的限流规则
+type RateLimitRule struct {
+	Name       string
+	Path       string
+	Requests   int
+	Window     time.Duration
+	Burst      int
+	Concurrent int
+}
+
+// rateVisitor 单个客户端在某条规则下的状态
+type rateVisitor struct {
+	limiter  *rate.Limiter
+	active   int
+	lastSeen time.Time
+}
+
+// RateLimiter 按客户端IP限流的中间件
+type RateLimiter struct {
+	enabled bool
+	rules   map[string]*RateLimitRule
+
+	mu       sync.Mutex
+	visitors map[string]*rateVisitor
+
+	unknownOnce sync.Once // 无法确定客户端IP时只提示一次
+}
+
+// NewRateLimiter 创建新的限流器
+func NewRateLimiter() *RateLimiter {
+	rl := &RateLimiter{
+		rules:    make(map[string]*RateLimitRule),
+		visitors: make(map[string]*rateVisitor),
+	}
+	go rl.cleanupLoop()
+	return rl
+}
+
+// LoadFromConfigFile 从配置文件的 [ratelimit] 部分加载限流规则
+func (rl *RateLimiter) LoadFromConfigFile(configData []byte) error {
+	cfg, err := ini.Load(configData)
+	if err != nil {
+		return fmt.Errorf("解析配置文件失败: %v", err)
+	}
+
+	section := cfg.Section("ratelimit")
+	rl.enabled = section.Key("enabled").MustBool(false)
+	rl.rules = make(map[string]*RateLimitRule)
+
+	// 解析 key 格式: route.field
+	ruleMap := make(map[string]map[string]string)
+	for _, key := range section.KeyStrings() {
+		parts := strings.Split(key, ".")
+		if len(parts) != 2 {
+			continue
+		}
+		if ruleMap[parts[0]] == nil {
+			ruleMap[parts[0]] = make(map[string]string)
+		}
+		ruleMap[parts[0]][parts[1]] = strings.TrimSpace(section.Key(key).String())
+	}
+
+	for name, fields := range ruleMap {
+		path, ok := fields["path"]
+		if !ok || path == "" {
+			continue
+		}
+		rule := &RateLimitRule{
+			Name:       name,
+			Path:       path,
+			Requests:   atoiDefault(fields["requests"], 0),
+			Window:     time.Duration(atoiDefault(fields["window"], 60)) * time.Second,
+			Burst:      atoiDefault(fields["burst"], 0),
+			Concurrent: atoiDefault(fields["concurrent"], 0),
+		}
+		if rule.Window <= 0 {
+			rule.Window = time.Minute
+		}
+		if rule.Burst <= 0 {
+			rule.Burst = rule.Requests
+		}
+		rl.rules[path] = rule
+	}
+
+	return nil
+}
+
+// atoiDefault 解析整数，失败时返回默认值
+func atoiDefault(s string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return n
+	}
+	return def
+}
+
+// Enabled 是否启用限流
+func (rl *RateLimiter) Enabled() bool {
+	return rl.enabled
+}
+
+// visitor 获取或创建客户端在指定规则下的状态，调用方需持有锁
+func (rl *RateLimiter) visitor(rule *RateLimitRule, clientIP string) *rateVisitor {
+	key := rule.Name + "|" + clientIP
+	v, ok := rl.visitors[key]
+	if !ok {
+		limit := rate.Inf
+		if rule.Requests > 0 {
+			limit = rate.Limit(float64(rule.Requests) / rule.Window.Seconds())
+		}
+		v = &rateVisitor{limiter: rate.NewLimiter(limit, rule.Burst)}
+		rl.visitors[key] = v
+	}
+	v.lastSeen = time.Now()
+	return v
+}
+
+// cleanupLoop 定期清理空闲的客户端状态
+func (rl *RateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		rl.mu.Lock()
+		for key, v := range rl.visitors {
+			if v.active == 0 && now.Sub(v.lastSeen) > 10*time.Minute {
+				delete(rl.visitors, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// Wrap 为指定路径的处理函数添加限流，未配置规则或未启用时原样返回
+func (rl *RateLimiter) Wrap(path string, next http.HandlerFunc) http.HandlerFunc {
+	rule, ok := rl.rules[path]
+	if !rl.enabled || !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 预检请求不计入限流
+		if r.Method == "OPTIONS" {
+			next(w, r)
+			return
+		}
+
+		// 无法确定客户端IP时（如 Unix socket 前的反向代理未添加 X-Forwarded-For），
+		// 所有请求会落入同一个桶而互相限流，此时不做按IP的限流
+		ip := utils.ResolveRequestIP(r)
+		if ip == nil {
+			rl.unknownOnce.Do(func() {
+				ratelimitLog.WarnContext(r.Context(), "⚠️ 无法确定客户端IP，跳过按IP限流，请让反向代理添加 X-Forwarded-For 或 Forwarded 头部", "path", path)
+			})
+			next(w, r)
+			return
+		}
+		clientIP := ip.String()
+
+		rl.mu.Lock()
+		v := rl.visitor(rule, clientIP)
+
+		if rule.Concurrent > 0 && v.active >= rule.Concurrent {
+			rl.mu.Unlock()
+			ratelimitLog.WarnContext(r.Context(), "🚫 并发连接超过限制", "path", path, "limit", rule.Concurrent)
+			writeTooManyRequests(w, time.Second, "Too many concurrent requests")
+			return
+		}
+
+		reservation := v.limiter.Reserve()
+		if !reservation.OK() {
+			rl.mu.Unlock()
+			writeTooManyRequests(w, rule.Window, "Too many requests")
+			return
+		}
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			rl.mu.Unlock()
+			ratelimitLog.WarnContext(r.Context(), "🚫 请求频率超过限制", "path", path)
+			writeTooManyRequests(w, delay, "Too many requests")
+			return
+		}
+
+		v.active++
+		rl.mu.Unlock()
+
+		defer func() {
+			rl.mu.Lock()
+			v.active--
+			v.lastSeen = time.Now()
+			rl.mu.Unlock()
+		}()
+
+		next(w, r)
+	}
+}
+
+// writeTooManyRequests 返回 429 响应并设置 Retry-After
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     false,
+		"message":     message,
+		"retry_after": seconds,
+	})
+}
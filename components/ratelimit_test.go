@@ -0,0 +1,151 @@
+package components
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+const testRateLimitConfig = `
+[ratelimit]
+enabled = true
+api.path = /api
+api.requests = 2
+api.window = 60
+stream.path = /stream
+stream.concurrent = 1
+`
+
+func newTestRateLimiter(t *testing.T, config string) *RateLimiter {
+	t.Helper()
+	rl := NewRateLimiter()
+	if err := rl.LoadFromConfigFile([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+func rateLimitRequest(h http.HandlerFunc, method, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api", nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestRateLimiterRequests(t *testing.T) {
+	rl := newTestRateLimiter(t, testRateLimitConfig)
+	h := rl.Wrap("/api", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		method     string
+		remoteAddr string
+		want       int
+	}{
+		{"第一个请求", "GET", "192.0.2.1:1000", http.StatusOK},
+		{"第二个请求", "GET", "192.0.2.1:1001", http.StatusOK},
+		{"超过突发上限", "GET", "192.0.2.1:1002", http.StatusTooManyRequests},
+		{"预检请求不计入", "OPTIONS", "192.0.2.1:1003", http.StatusOK},
+		{"其他客户端独立计数", "GET", "192.0.2.2:1000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := rateLimitRequest(h, tt.method, tt.remoteAddr)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if tt.want == http.StatusTooManyRequests {
+			// 每 30 秒补充一个令牌
+			retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+			if err != nil || retry < 1 || retry > 30 {
+				t.Errorf("%s: Retry-After = %q", tt.name, w.Header().Get("Retry-After"))
+			}
+		}
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	rl := newTestRateLimiter(t, testRateLimitConfig)
+
+	var inner *httptest.ResponseRecorder
+	var h http.HandlerFunc
+	h = rl.Wrap("/stream", func(w http.ResponseWriter, r *http.Request) {
+		if inner == nil {
+			// 第一个连接尚未结束时发起第二个连接
+			inner = rateLimitRequest(h, "GET", r.RemoteAddr)
+		}
+	})
+
+	if w := rateLimitRequest(h, "GET", "192.0.2.1:1000"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if inner.Code != http.StatusTooManyRequests {
+		t.Errorf("并发请求 status = %d, want 429", inner.Code)
+	}
+	if got := inner.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	// 连接结束后释放并发名额
+	inner = nil
+	if w := rateLimitRequest(h, "GET", "192.0.2.1:1001"); w.Code != http.StatusOK {
+		t.Errorf("释放后 status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		path   string
+	}{
+		{"未启用", "[ratelimit]\nenabled = false\napi.path = /api\napi.requests = 1\n", "/api"},
+		{"未配置的路径", testRateLimitConfig, "/other"},
+		{"缺少 path 的规则被忽略", "[ratelimit]\nenabled = true\napi.requests = 1\n", "/api"},
+	}
+	for _, tt := range tests {
+		rl := newTestRateLimiter(t, tt.config)
+		h := rl.Wrap(tt.path, func(w http.ResponseWriter, r *http.Request) {})
+		for i := 0; i < 5; i++ {
+			if w := rateLimitRequest(h, "GET", "192.0.2.1:1000"); w.Code != http.StatusOK {
+				t.Fatalf("%s: 第 %d 个请求 status = %d, want 200", tt.name, i+1, w.Code)
+			}
+		}
+	}
+}
+
+func TestRateLimiterUnknownClient(t *testing.T) {
+	rl := newTestRateLimiter(t, testRateLimitConfig)
+	h := rl.Wrap("/api", func(w http.ResponseWriter, r *http.Request) {})
+
+	// Unix socket 前的反向代理未添加转发头部时无法确定客户端IP，不按IP限流
+	unixAddr := &net.UnixAddr{Name: "/run/vastvideo.sock", Net: "unix"}
+	for i := 0; i < 5; i++ {
+		r := httptest.NewRequest("GET", "/api", nil)
+		r.RemoteAddr = "@"
+		r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, unixAddr))
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("第 %d 个请求 status = %d, want 200", i+1, w.Code)
+		}
+	}
+	if n := len(rl.visitors); n != 0 {
+		t.Errorf("无法确定IP的请求不应登记客户端状态, visitors = %d", n)
+	}
+
+	// 带有转发头部时按其中的客户端IP限流
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest("GET", "/api", nil)
+		r.RemoteAddr = "@"
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+		r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, unixAddr))
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != want {
+			t.Errorf("带转发头部的第 %d 个请求 status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// scorpioLog scorpio 资源检测与提升日志
+var scorpioLog = utils.Logger("scorpio")
+
+// ScorpioJsonPath scorpio.json 默认路径
+const ScorpioJsonPath = "config/scorpio.json"
+
+// ScorpioSource 结构体
+// 用于解析和保存 scorpio.json 的每个资源
+type ScorpioSource struct {
+	Name          string `json:"name"`
+	API           string `json:"api"`
+	LastCheckTime int64  `json:"last_check_time,omitempty"`
+	IsValid       *bool  `json:"is_valid,omitempty"`
+	LatencyMs     int64  `json:"latency_ms,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// LoadScorpioSources 读取scorpio.json
+func LoadScorpioSources(path string) ([]*ScorpioSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sources []*ScorpioSource
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// SaveScorpioSources 保存scorpio.json
+func SaveScorpioSources(path string, sources []*ScorpioSource) error {
+	data, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ResponseLevel 响应时间评级
+func ResponseLevel(cost int64) string {
+	if cost > 8000 {
+		return "慢"
+	} else if cost > 3000 {
+		return "中"
+	}
+	return "快"
+}
+
+// ScorpioManager scorpio.json 资源管理器，负责后台定时检测
+type ScorpioManager struct {
+	path        string
+	concurrency int
+	jitter      time.Duration
+
+	options ValidateOptions
+	history *ScorpioHistory
+	onRound func()
+
+	mu        sync.RWMutex
+	sources   []*ScorpioSource
+	checking  bool
+	lastRound time.Time
+	nextRound time.Time
+}
+
+// NewScorpioManager 创建新的 scorpio 资源管理器
+func NewScorpioManager(path string, history *ScorpioHistory, options ValidateOptions) *ScorpioManager {
+	return &ScorpioManager{
+		path:        path,
+		concurrency: 8,
+		options:     options,
+		history:     history,
+	}
+}
+
+// SetRoundHook 设置每轮后台检测完成并保存后的回调
+func (sm *ScorpioManager) SetRoundHook(hook func()) {
+	sm.onRound = hook
+}
+
+// Load 从文件加载资源列表
+func (sm *ScorpioManager) Load() error {
+	sources, err := LoadScorpioSources(sm.path)
+	if err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	sm.sources = sources
+	sm.mu.Unlock()
+	return nil
+}
+
+// Save 将当前资源列表和检测历史写回文件
+func (sm *ScorpioManager) Save() error {
+	sm.mu.RLock()
+	err := SaveScorpioSources(sm.path, sm.sources)
+	sm.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return sm.history.Save()
+}
+
+// Sources 返回资源列表的快照
+func (sm *ScorpioManager) Sources() []ScorpioSource {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	list := make([]ScorpioSource, 0, len(sm.sources))
+	for _, s := range sm.sources {
+		list = append(list, *s)
+	}
+	return list
+}
+
+// HasAPI 判断接口地址是否属于 scorpio 资源
+func (sm *ScorpioManager) HasAPI(api string) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	for _, s := range sm.sources {
+		if s.API == api {
+			return true
+		}
+	}
+	return false
+}
+
+// recordResult 记录单个资源的检测结论，并追加到检测历史
+func (sm *ScorpioManager) recordResult(s *ScorpioSource, verdict *SourceVerdict) {
+	isValid := verdict.Valid
+	now := time.Now().Unix()
+
+	sm.mu.Lock()
+	s.LastCheckTime = now
+	s.IsValid = &isValid
+	s.LatencyMs = verdict.LatencyMs
+	s.LastError = ""
+	if !isValid {
+		s.LastError = verdict.Summary()
+	}
+	name := s.Name
+	sm.mu.Unlock()
+
+	sm.history.Add(name, CheckRecord{
+		Time:       now,
+		Success:    isValid,
+		LatencyMs:  verdict.LatencyMs,
+		HTTPStatus: verdict.HTTPStatus,
+		ErrorClass: verdict.ErrorClass,
+	})
+}
+
+// beginRound 开始一轮检测，同一时间只允许一轮检测，返回本轮要检测的资源
+func (sm *ScorpioManager) beginRound() ([]*ScorpioSource, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.checking {
+		return nil, fmt.Errorf("检测正在进行中")
+	}
+	sm.checking = true
+	sources := make([]*ScorpioSource, len(sm.sources))
+	copy(sources, sm.sources)
+	return sources, nil
+}
+
+// endRound 结束一轮检测
+func (sm *ScorpioManager) endRound() {
+	sm.mu.Lock()
+	sm.checking = false
+	sm.lastRound = time.Now()
+	sm.mu.Unlock()
+}
+
+// runChecks 使用固定数量的 worker 检测资源，每个结果记录后回调 onResult
+// ctx 取消后不再派发新任务，已在进行中的检测完成后返回
+func (sm *ScorpioManager) runChecks(ctx context.Context, sources []*ScorpioSource, concurrency int, jitter time.Duration,
+	onResult func(idx int, s *ScorpioSource, verdict *SourceVerdict)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				// 随机抖动，避免同时请求大量上游
+				if jitter > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Duration(rand.Int63n(int64(jitter)))):
+					}
+				}
+
+				s := sources[idx]
+				verdict := ValidateMacCMSSource(s.API, sm.options)
+				sm.recordResult(s, verdict)
+				onResult(idx, s, verdict)
+			}
+		}()
+	}
+
+dispatch:
+	for idx := range sources {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- idx:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// CheckAll 以有限并发检测所有资源，全部完成后统一保存一次
+func (sm *ScorpioManager) CheckAll(ctx context.Context) error {
+	sources, err := sm.beginRound()
+	if err != nil {
+		return err
+	}
+	defer sm.endRound()
+
+	start := time.Now()
+	var valid int64
+	sm.runChecks(ctx, sources, sm.concurrency, sm.jitter, func(_ int, _ *ScorpioSource, verdict *SourceVerdict) {
+		if verdict.Valid {
+			atomic.AddInt64(&valid, 1)
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	scorpioLog.Info("🩺 scorpio 资源检测完成", "valid", valid, "total", len(sources), "elapsed", time.Since(start).Round(time.Second))
+	if err := sm.Save(); err != nil {
+		return err
+	}
+	if sm.onRound != nil {
+		sm.onRound()
+	}
+	return nil
+}
+
+// StartScheduler 启动后台定时检测
+// 首次检测时间根据上次检测时间推算，避免每次重启都立即全量检测
+func (sm *ScorpioManager) StartScheduler(ctx context.Context, interval time.Duration, concurrency int, jitter time.Duration) {
+	if concurrency > 0 {
+		sm.concurrency = concurrency
+	}
+	sm.jitter = jitter
+
+	var newest int64
+	for _, s := range sm.Sources() {
+		if s.LastCheckTime > newest {
+			newest = s.LastCheckTime
+		}
+	}
+	delay := interval - time.Since(time.Unix(newest, 0))
+	if delay < 0 {
+		delay = 0
+	}
+	delay += jitter
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		for {
+			sm.mu.Lock()
+			sm.nextRound = time.Now().Add(delay)
+			sm.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			if err := sm.CheckAll(ctx); err != nil {
+				scorpioLog.Warn("⚠️ scorpio 资源检测失败", "error", err)
+			}
+			delay = interval
+			timer.Reset(delay)
+		}
+	}()
+}
+
+// scorpioSourceView 资源列表接口中的单个资源，附带可用性统计摘要
+type scorpioSourceView struct {
+	ScorpioSource
+	Stats *SourceStats `json:"stats,omitempty"`
+}
+
+// HandleScorpioSourcesAPI 处理 /api/scorpio_sources 及其子路径接口
+func (sm *ScorpioManager) HandleScorpioSourcesAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 子路径: /api/scorpio_sources/{name}/history
+	rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api/scorpio_sources"), "/")
+	if rest != "" {
+		parts := strings.Split(rest, "/")
+		name, err := url.PathUnescape(parts[0])
+		if err != nil || len(parts) != 2 || parts[1] != "history" {
+			http.NotFound(w, r)
+			return
+		}
+		sm.handleHistory(w, r, name)
+		return
+	}
+
+	sources := sm.Sources()
+	views := make([]scorpioSourceView, 0, len(sources))
+	for _, s := range sources {
+		views = append(views, scorpioSourceView{
+			ScorpioSource: s,
+			Stats:         sm.history.Stats(s.Name),
+		})
+	}
+
+	sm.mu.RLock()
+	scheduler := map[string]interface{}{
+		"checking": sm.checking,
+	}
+	if !sm.lastRound.IsZero() {
+		scheduler["last_round"] = sm.lastRound.Unix()
+	}
+	if !sm.nextRound.IsZero() {
+		scheduler["next_round"] = sm.nextRound.Unix()
+	}
+	sm.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"data":      views,
+		"count":     len(views),
+		"scheduler": scheduler,
+	})
+}
+
+// handleHistory 返回单个资源的检测历史和统计
+func (sm *ScorpioManager) handleHistory(w http.ResponseWriter, r *http.Request, name string) {
+	var source *ScorpioSource
+	for _, s := range sm.Sources() {
+		if s.Name == name {
+			s := s
+			source = &s
+			break
+		}
+	}
+	if source == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Source not found",
+			"data":    nil,
+		})
+		return
+	}
+
+	records := sm.history.Records(name)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"source":  source,
+			"stats":   sm.history.Stats(name),
+			"history": records,
+		},
+		"count": len(records),
+	})
+}
@@ -0,0 +1,170 @@
+package components
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScorpioHistoryPath 检测历史默认保存路径
+const ScorpioHistoryPath = "config/scorpio_history.json"
+
+// CheckRecord 单次检测记录
+type CheckRecord struct {
+	Time       int64  `json:"time"`
+	Success    bool   `json:"success"`
+	LatencyMs  int64  `json:"latency_ms"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+}
+
+// SourceStats 资源可用性统计
+type SourceStats struct {
+	Checks          int     `json:"checks"`
+	UptimePercent   float64 `json:"uptime_percent"`
+	P50LatencyMs    int64   `json:"p50_latency_ms"`
+	P95LatencyMs    int64   `json:"p95_latency_ms"`
+	LastSuccessTime int64   `json:"last_success_time,omitempty"`
+}
+
+// ScorpioHistory 按资源名称保存滚动检测历史
+type ScorpioHistory struct {
+	path       string
+	maxRecords int
+
+	mu      sync.RWMutex
+	records map[string][]CheckRecord
+}
+
+// NewScorpioHistory 创建检测历史存储，每个资源最多保留 maxRecords 条记录
+func NewScorpioHistory(path string, maxRecords int) *ScorpioHistory {
+	if maxRecords <= 0 {
+		maxRecords = 200
+	}
+	return &ScorpioHistory{
+		path:       path,
+		maxRecords: maxRecords,
+		records:    make(map[string][]CheckRecord),
+	}
+}
+
+// Load 从文件加载检测历史，文件不存在时视为空历史
+func (h *ScorpioHistory) Load() error {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	records := make(map[string][]CheckRecord)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.records = records
+	h.mu.Unlock()
+	return nil
+}
+
+// Save 将检测历史写回文件
+func (h *ScorpioHistory) Save() error {
+	h.mu.RLock()
+	data, err := json.Marshal(h.records)
+	h.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, data, 0644)
+}
+
+// Add 追加一条检测记录，超出上限时丢弃最旧的记录
+func (h *ScorpioHistory) Add(name string, rec CheckRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := append(h.records[name], rec)
+	if len(list) > h.maxRecords {
+		list = list[len(list)-h.maxRecords:]
+	}
+	h.records[name] = list
+}
+
+// Records 返回指定资源的检测记录副本
+func (h *ScorpioHistory) Records(name string) []CheckRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	list := make([]CheckRecord, len(h.records[name]))
+	copy(list, h.records[name])
+	return list
+}
+
+// Stats 计算指定资源的可用率、延迟分位数和最近成功时间，无记录时返回 nil
+func (h *ScorpioHistory) Stats(name string) *SourceStats {
+	records := h.Records(name)
+	if len(records) == 0 {
+		return nil
+	}
+
+	stats := &SourceStats{Checks: len(records)}
+	var latencies []int64
+	success := 0
+	for _, rec := range records {
+		if !rec.Success {
+			continue
+		}
+		success++
+		latencies = append(latencies, rec.LatencyMs)
+		if rec.Time > stats.LastSuccessTime {
+			stats.LastSuccessTime = rec.Time
+		}
+	}
+	stats.UptimePercent = float64(success) * 100 / float64(len(records))
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.P50LatencyMs = percentile(latencies, 50)
+		stats.P95LatencyMs = percentile(latencies, 95)
+	}
+	return stats
+}
+
+// percentile 计算已排序数据的分位数（最近秩法）
+func percentile(sorted []int64, p int) int64 {
+	idx := (len(sorted)*p+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// classifyCheckError 将请求错误归类，便于区分资源失效的原因
+func classifyCheckError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns"
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "connection refused"):
+		return "connection_refused"
+	case strings.Contains(msg, "connection reset"):
+		return "connection_reset"
+	case strings.Contains(msg, "tls") || strings.Contains(msg, "x509") || strings.Contains(msg, "certificate"):
+		return "tls"
+	}
+	return "network"
+}
@@ -0,0 +1,162 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		data []int64
+		p    int
+		want int64
+	}{
+		{[]int64{100}, 50, 100},
+		{[]int64{100}, 95, 100},
+		{[]int64{10, 20}, 50, 10},
+		{[]int64{10, 20}, 95, 20},
+		{[]int64{10, 20, 30, 40}, 50, 20},
+		{[]int64{10, 20, 30, 40}, 95, 40},
+		{[]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 50, 5},
+		{[]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 95, 10},
+		{[]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0, 1},
+		{[]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.data, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %d) = %d, want %d", tt.data, tt.p, got, tt.want)
+		}
+	}
+
+	// 最近秩法：第 ceil(n*p/100) 个值
+	data := make([]int64, 100)
+	for i := range data {
+		data[i] = int64(i + 1)
+	}
+	if got := percentile(data, 95); got != 95 {
+		t.Errorf("percentile(1..100, 95) = %d, want 95", got)
+	}
+}
+
+func TestScorpioHistoryStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		records     []CheckRecord
+		wantUptime  float64
+		wantP50     int64
+		wantP95     int64
+		wantSuccess int64
+	}{
+		{"全部成功", []CheckRecord{
+			{Time: 1, Success: true, LatencyMs: 300},
+			{Time: 2, Success: true, LatencyMs: 100},
+			{Time: 3, Success: true, LatencyMs: 200},
+		}, 100, 200, 300, 3},
+		{"部分失败时只统计成功的延迟", []CheckRecord{
+			{Time: 1, Success: true, LatencyMs: 100},
+			{Time: 2, Success: false, LatencyMs: 15000},
+			{Time: 3, Success: true, LatencyMs: 300},
+		}, 200.0 / 3, 100, 300, 3},
+		{"最近成功时间取最大值", []CheckRecord{
+			{Time: 5, Success: true, LatencyMs: 100},
+			{Time: 9, Success: false},
+			{Time: 2, Success: true, LatencyMs: 100},
+		}, 200.0 / 3, 100, 100, 5},
+		{"全部失败", []CheckRecord{
+			{Time: 1, Success: false},
+			{Time: 2, Success: false},
+			{Time: 3, Success: false},
+			{Time: 4, Success: false},
+		}, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewScorpioHistory("", 0)
+			for _, rec := range tt.records {
+				h.Add("a", rec)
+			}
+			stats := h.Stats("a")
+			if stats == nil {
+				t.Fatal("Stats() = nil")
+			}
+			if stats.Checks != len(tt.records) || math.Abs(stats.UptimePercent-tt.wantUptime) > 1e-9 {
+				t.Errorf("checks = %d, uptime = %v, want %d, %v", stats.Checks, stats.UptimePercent, len(tt.records), tt.wantUptime)
+			}
+			if stats.P50LatencyMs != tt.wantP50 || stats.P95LatencyMs != tt.wantP95 {
+				t.Errorf("p50 = %d, p95 = %d, want %d, %d", stats.P50LatencyMs, stats.P95LatencyMs, tt.wantP50, tt.wantP95)
+			}
+			if stats.LastSuccessTime != tt.wantSuccess {
+				t.Errorf("last_success_time = %d, want %d", stats.LastSuccessTime, tt.wantSuccess)
+			}
+		})
+	}
+
+	if stats := NewScorpioHistory("", 0).Stats("none"); stats != nil {
+		t.Errorf("没有记录时 Stats() = %+v, want nil", stats)
+	}
+}
+
+func TestScorpioHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h := NewScorpioHistory(path, 3)
+	for i := 1; i <= 5; i++ {
+		h.Add("a", CheckRecord{Time: int64(i), Success: true, LatencyMs: int64(i * 100)})
+	}
+	h.Add("b", CheckRecord{Time: 1, ErrorClass: "timeout"})
+
+	records := h.Records("a")
+	if len(records) != 3 || records[0].Time != 3 || records[2].Time != 5 {
+		t.Fatalf("超出上限后应保留最新的记录: %+v", records)
+	}
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewScorpioHistory(path, 3)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Records("a"); len(got) != 3 || got[2].LatencyMs != 500 {
+		t.Errorf("重新加载后的记录 = %+v", got)
+	}
+	if got := loaded.Records("b"); len(got) != 1 || got[0].ErrorClass != "timeout" {
+		t.Errorf("重新加载后的记录 = %+v", got)
+	}
+
+	if err := NewScorpioHistory(filepath.Join(t.TempDir(), "missing.json"), 0).Load(); err != nil {
+		t.Errorf("文件不存在时 Load() = %v, want nil", err)
+	}
+}
+
+// timeoutError 模拟超时的网络错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyCheckError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("Get: %w", timeoutError{}), "timeout"},
+		{context.DeadlineExceeded, "timeout"},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, "dns"},
+		{errors.New("dial tcp: connect: connection refused"), "connection_refused"},
+		{errors.New("read: connection reset by peer"), "connection_reset"},
+		{errors.New("x509: certificate signed by unknown authority"), "tls"},
+		{errors.New("unexpected EOF"), "network"},
+	}
+	for _, tt := range tests {
+		if got := classifyCheckError(tt.err); got != tt.want {
+			t.Errorf("classifyCheckError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 流式检测的最大并发数
+const maxStreamConcurrency = 32
+
+// checkEvent 流式检测中单个资源的结果事件
+type checkEvent struct {
+	Index         int            `json:"index"`
+	Name          string         `json:"name"`
+	API           string         `json:"api"`
+	LastCheckTime int64          `json:"last_check_time"`
+	IsValid       bool           `json:"is_valid"`
+	Verdict       *SourceVerdict `json:"verdict"`
+	ResponseTime  int64          `json:"response_time"`
+	ResponseLevel string         `json:"response_level"`
+	ResultJSON    interface{}    `json:"result_json,omitempty"`
+}
+
+// HandleCheckStreamAPI 处理 /check_sources/stream 接口，以 SSE 流式返回全部资源的检测结果
+// 检测由固定数量的 worker 执行，结果经由通道交给当前 goroutine 统一写出；
+// 客户端断开时停止派发任务，全部结束后发送 summary 事件并统一保存一次
+func (sm *ScorpioManager) HandleCheckStreamAPI(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	sources, err := sm.beginRound()
+	if err != nil {
+		writeSSE(w, "check_error", map[string]interface{}{"message": err.Error()})
+		flusher.Flush()
+		return
+	}
+	defer sm.endRound()
+
+	concurrency := sm.concurrency
+	if n, err := strconv.Atoi(r.URL.Query().Get("concurrency")); err == nil && n > 0 {
+		concurrency = n
+	}
+	if concurrency > maxStreamConcurrency {
+		concurrency = maxStreamConcurrency
+	}
+
+	ctx := r.Context()
+	start := time.Now()
+	scorpioLog.InfoContext(r.Context(), "🩺 开始流式检测 scorpio 资源", "total", len(sources), "concurrency", concurrency)
+
+	writeSSE(w, "start", map[string]interface{}{
+		"total":       len(sources),
+		"concurrency": concurrency,
+	})
+	flusher.Flush()
+
+	events := make(chan checkEvent)
+	go func() {
+		defer close(events)
+		sm.runChecks(ctx, sources, concurrency, 0, func(idx int, s *ScorpioSource, verdict *SourceVerdict) {
+			ev := checkEvent{
+				Index:         idx,
+				Name:          s.Name,
+				API:           s.API,
+				LastCheckTime: time.Now().Unix(),
+				IsValid:       verdict.Valid,
+				Verdict:       verdict,
+				ResponseTime:  verdict.LatencyMs,
+				ResponseLevel: ResponseLevel(verdict.LatencyMs),
+			}
+			if verdict.Valid {
+				ev.ResultJSON = verdict.Sample
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	// 唯一的写出者：顺序写出结果事件，并定期发送心跳保持连接
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	checked, valid := 0, 0
+	for done := false; !done; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			checked++
+			if ev.IsValid {
+				valid++
+			}
+			if ctx.Err() == nil {
+				writeSSE(w, "", ev)
+				flusher.Flush()
+			}
+		case <-heartbeat.C:
+			if ctx.Err() == nil {
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+
+	if err := sm.Save(); err != nil {
+		scorpioLog.Warn("⚠️ 保存 scorpio 检测结果失败", "error", err)
+	}
+
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if ctx.Err() != nil {
+		scorpioLog.InfoContext(r.Context(), "🩺 流式检测被客户端中断", "checked", checked, "total", len(sources))
+		return
+	}
+
+	writeSSE(w, "summary", map[string]interface{}{
+		"total":      len(sources),
+		"checked":    checked,
+		"valid":      valid,
+		"invalid":    checked - valid,
+		"elapsed_ms": elapsed.Milliseconds(),
+	})
+	flusher.Flush()
+	scorpioLog.InfoContext(r.Context(), "🩺 流式检测完成", "valid", valid, "checked", checked, "elapsed", elapsed)
+}
+
+// writeSSE 写出一条 SSE 事件，event 为空时使用默认的 message 事件
+func writeSSE(w http.ResponseWriter, event string, data interface{}) {
+	b, _ := json.Marshal(data)
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", b)
+}
@@ -0,0 +1,131 @@
+package components
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sseEvent 解析后的 SSE 事件
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSE 读取全部 SSE 事件，忽略注释行
+func readSSE(t *testing.T, url string) []sseEvent {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var events []sseEvent
+	var ev sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if ev.data != "" {
+				if ev.name == "" {
+					ev.name = "message"
+				}
+				events = append(events, ev)
+			}
+			ev = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return events
+}
+
+func TestHandleCheckStreamAPI(t *testing.T) {
+	good := newFakeMacCMS(t, fakeMacCMS{})
+	bad := newFakeMacCMS(t, fakeMacCMS{protocol: "not json"})
+	sm := newTestScorpioManager(t,
+		&ScorpioSource{Name: "good-1", API: good},
+		&ScorpioSource{Name: "bad", API: bad},
+		&ScorpioSource{Name: "good-2", API: good},
+	)
+	srv := httptest.NewServer(http.HandlerFunc(sm.HandleCheckStreamAPI))
+	defer srv.Close()
+
+	events := readSSE(t, srv.URL+"?concurrency=100")
+	if len(events) != 5 || events[0].name != "start" || events[4].name != "summary" {
+		t.Fatalf("events = %+v", events)
+	}
+
+	var start struct {
+		Total       int `json:"total"`
+		Concurrency int `json:"concurrency"`
+	}
+	json.Unmarshal([]byte(events[0].data), &start)
+	if start.Total != 3 || start.Concurrency != maxStreamConcurrency {
+		t.Errorf("start = %+v", start)
+	}
+
+	seen := make(map[int]bool)
+	for _, ev := range events[1:4] {
+		var result checkEvent
+		if err := json.Unmarshal([]byte(ev.data), &result); err != nil {
+			t.Fatal(err)
+		}
+		seen[result.Index] = true
+		if wantValid := result.Name != "bad"; result.IsValid != wantValid || result.Verdict == nil {
+			t.Errorf("%s: is_valid = %v, want %v", result.Name, result.IsValid, wantValid)
+		}
+		if result.IsValid && result.ResultJSON == nil {
+			t.Errorf("%s: 可用资源应附带样本数据", result.Name)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("结果事件下标 = %v", seen)
+	}
+
+	var summary map[string]int64
+	json.Unmarshal([]byte(events[4].data), &summary)
+	if summary["total"] != 3 || summary["checked"] != 3 || summary["valid"] != 2 || summary["invalid"] != 1 {
+		t.Errorf("summary = %v", summary)
+	}
+
+	// 全部检测完成后统一保存一次
+	saved, err := LoadScorpioSources(sm.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range saved {
+		if s.IsValid == nil {
+			t.Errorf("%s: 检测结果未保存", s.Name)
+		}
+	}
+	if records := sm.history.Records("good-1"); len(records) != 1 {
+		t.Errorf("检测历史 = %+v", records)
+	}
+}
+
+func TestHandleCheckStreamAPIBusy(t *testing.T) {
+	sm := newTestScorpioManager(t, &ScorpioSource{Name: "a", API: "http://127.0.0.1:1/"})
+	if _, err := sm.beginRound(); err != nil {
+		t.Fatal(err)
+	}
+	defer sm.endRound()
+
+	srv := httptest.NewServer(http.HandlerFunc(sm.HandleCheckStreamAPI))
+	defer srv.Close()
+	events := readSSE(t, srv.URL)
+	if len(events) != 1 || events[0].name != "check_error" {
+		t.Errorf("已有检测进行中时 events = %+v", events)
+	}
+}
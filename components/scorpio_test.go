@@ -0,0 +1,150 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestScorpioManager 创建包含指定资源的 scorpio 管理器，资源与检测历史保存在临时目录
+func newTestScorpioManager(t *testing.T, sources ...*ScorpioSource) *ScorpioManager {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scorpio.json")
+	if err := SaveScorpioSources(path, sources); err != nil {
+		t.Fatal(err)
+	}
+	history := NewScorpioHistory(filepath.Join(dir, "history.json"), 0)
+	sm := NewScorpioManager(path, history, ValidateOptions{Timeout: 5 * time.Second})
+	if err := sm.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return sm
+}
+
+func TestScorpioCheckAll(t *testing.T) {
+	good := newFakeMacCMS(t, fakeMacCMS{})
+	bad := newFakeMacCMS(t, fakeMacCMS{status: http.StatusInternalServerError})
+	sm := newTestScorpioManager(t,
+		&ScorpioSource{Name: "good", API: good},
+		&ScorpioSource{Name: "bad", API: bad},
+	)
+	rounds := 0
+	sm.SetRoundHook(func() { rounds++ })
+
+	for i := 0; i < 2; i++ {
+		if err := sm.CheckAll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rounds != 2 {
+		t.Errorf("回调次数 = %d, want 2", rounds)
+	}
+
+	// 检测结果应写回文件
+	saved, err := LoadScorpioSources(sm.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"good": true, "bad": false}
+	for _, s := range saved {
+		if s.IsValid == nil || *s.IsValid != want[s.Name] || s.LastCheckTime == 0 {
+			t.Errorf("%s: is_valid = %v, last_check_time = %d", s.Name, s.IsValid, s.LastCheckTime)
+		}
+		if !want[s.Name] && s.LastError == "" {
+			t.Errorf("%s: 检测失败时应记录原因", s.Name)
+		}
+	}
+
+	history := NewScorpioHistory(sm.history.path, 0)
+	if err := history.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := history.Stats("good"); stats == nil || stats.Checks != 2 || stats.UptimePercent != 100 {
+		t.Errorf("good 统计 = %+v", stats)
+	}
+	if records := history.Records("bad"); len(records) != 2 || records[0].ErrorClass != "http_5xx" || records[0].HTTPStatus != 500 {
+		t.Errorf("bad 记录 = %+v", records)
+	}
+}
+
+func TestScorpioCheckAllConcurrentRound(t *testing.T) {
+	sm := newTestScorpioManager(t, &ScorpioSource{Name: "a", API: "http://127.0.0.1:1/"})
+	if _, err := sm.beginRound(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.CheckAll(context.Background()); err == nil {
+		t.Error("已有检测进行中时应返回错误")
+	}
+	sm.endRound()
+
+	// 取消后不再检测，也不保存
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sm.CheckAll(ctx); err != context.Canceled {
+		t.Errorf("CheckAll() = %v, want context.Canceled", err)
+	}
+	if s := sm.Sources()[0]; s.IsValid != nil {
+		t.Errorf("取消后不应检测资源: %+v", s)
+	}
+}
+
+func TestScorpioSourcesAPI(t *testing.T) {
+	valid := true
+	sm := newTestScorpioManager(t, &ScorpioSource{Name: "资源 A", API: "http://a.example.com/", IsValid: &valid})
+	sm.history.Add("资源 A", CheckRecord{Time: 1, Success: true, LatencyMs: 120})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCount  int
+	}{
+		{"资源列表", "GET", "/api/scorpio_sources", http.StatusOK, 1},
+		{"检测历史", "GET", "/api/scorpio_sources/" + url.PathEscape("资源 A") + "/history", http.StatusOK, 1},
+		{"资源不存在", "GET", "/api/scorpio_sources/none/history", http.StatusNotFound, 0},
+		{"无效的子路径", "GET", "/api/scorpio_sources/a/b/c", http.StatusNotFound, 0},
+		{"不支持的方法", "POST", "/api/scorpio_sources", http.StatusMethodNotAllowed, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		sm.HandleScorpioSourcesAPI(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Count != tt.wantCount {
+			t.Errorf("%s: 响应 = %s", tt.name, w.Body.String())
+		}
+	}
+}
+
+func TestScorpioHasAPI(t *testing.T) {
+	sm := newTestScorpioManager(t, &ScorpioSource{Name: "a", API: "https://a.example.com/api.php/provide/vod/"})
+	tests := []struct {
+		api  string
+		want bool
+	}{
+		{"https://a.example.com/api.php/provide/vod/", true},
+		{"https://a.example.com/api.php/provide/vod", false},
+		{"https://b.example.com/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := sm.HasAPI(tt.api); got != tt.want {
+			t.Errorf("HasAPI(%q) = %v, want %v", tt.api, got, tt.want)
+		}
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"vastproxy-go/utils"
@@ -16,12 +19,20 @@ import (
 	"gopkg.in/ini.v1"
 )
 
+// sourcesLog 视频源日志
+var sourcesLog = utils.Logger("sources")
+
 // VideoSource 视频源结构
 type VideoSource struct {
-	Code      string `json:"code"`
-	Name      string `json:"name"`
-	URL       string `json:"url"`
-	IsDefault bool   `json:"is_default"`
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	IsDefault bool     `json:"is_default"`
+	Adult     bool     `json:"adult,omitempty"`
+	Order     int      `json:"order"`             // 排序值（≥1），越小越靠前；0 表示未设置，排在设置了排序值的视频源之后；相同时保持原有顺序
+	Enabled   bool     `json:"enabled"`           // 停用的视频源不出现在列表中，也不可搜索
+	Mirrors   []string `json:"mirrors,omitempty"` // 备用镜像地址，主地址失败或熔断时依次尝试
+	Origin    string   `json:"origin,omitempty"`  // 运行时添加的来源，如 scorpio；为空表示来自 config.ini
 }
 
 // VideoItem 视频项目结构
@@ -39,30 +50,52 @@ type VideoItem struct {
 	VodTime     string `json:"vod_time"`
 	VodRemarks  string `json:"vod_remarks"`
 	VodPlayUrl  string `json:"vod_play_url"`
+	SourceCode  string `json:"source_code,omitempty"` // 聚合搜索时标记结果来源
+	SourceName  string `json:"source_name,omitempty"`
 }
 
 // SearchResponse 搜索响应结构
 type SearchResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    []VideoItem `json:"data"`
-	Count   int         `json:"count"`
+	Success  bool              `json:"success"`
+	Message  string            `json:"message"`
+	Data     []VideoItem       `json:"data"`
+	Count    int               `json:"count"`
+	Filtered int               `json:"filtered,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"` // 聚合搜索中失败的源及原因
 }
 
 // SourcesConfig 视频源配置管理器
 type SourcesConfig struct {
-	sources []VideoSource
+	mu        sync.RWMutex
+	sources   []VideoSource
+	filter    *ContentFilter
+	localPath string
+	removed   map[string]bool // 运行时移除的 config.ini 视频源
+	breakers  *BreakerGroup
+	cache     *searchCache
+
+	matchCache  *ResponseCache // 豆瓣匹配结果缓存
+	matchPolicy CachePolicy
+}
+
+// Endpoints 返回视频源的全部地址，主地址在前，镜像地址按配置顺序在后
+func (s *VideoSource) Endpoints() []string {
+	return append([]string{s.URL}, s.Mirrors...)
 }
 
 // NewSourcesConfig 创建新的视频源配置管理器
 func NewSourcesConfig() *SourcesConfig {
 	return &SourcesConfig{
-		sources: []VideoSource{},
+		sources:  []VideoSource{},
+		removed:  make(map[string]bool),
+		breakers: NewBreakerGroup(0, 0),
 	}
 }
 
 // LoadFromConfigFile 从配置文件加载视频源
 func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 	sc.sources = []VideoSource{}
 
 	// 解析INI配置文件
@@ -77,33 +110,41 @@ func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
 		return fmt.Errorf("配置文件中未找到 [sources] 部分")
 	}
 
-	// 用于临时存储源数据的map
+	// 用于临时存储源数据的map，codes 记录各源首次出现的顺序
 	sourceMap := make(map[string]map[string]string)
+	var codes []string
 
 	// 遍历所有配置项
 	for _, key := range sourcesSection.KeyStrings() {
 		value := sourcesSection.Key(key).String()
 
-		// 解析 key 格式: code.field
+		// 解析 key 格式: code.field，镜像地址为 code.url.N
 		parts := strings.Split(key, ".")
-		if len(parts) != 2 {
+		if len(parts) == 3 && parts[1] == "url" {
+			if n, err := strconv.Atoi(parts[2]); err != nil || n < 2 {
+				continue // 镜像序号从 2 开始
+			}
+		} else if len(parts) != 2 {
 			continue // 跳过格式不正确的配置
 		}
 
 		code := parts[0]
-		field := parts[1]
+		field := strings.Join(parts[1:], ".")
 
 		// 初始化源数据map
 		if sourceMap[code] == nil {
 			sourceMap[code] = make(map[string]string)
+			codes = append(codes, code)
 		}
 
 		// 存储字段值
 		sourceMap[code][field] = strings.TrimSpace(value)
 	}
 
-	// 从map构建VideoSource对象
-	for code, fields := range sourceMap {
+	// 按声明顺序构建VideoSource对象
+	for _, code := range codes {
+		fields := sourceMap[code]
+
 		// 检查必需字段
 		name, hasName := fields["name"]
 		url, hasURL := fields["url"]
@@ -112,9 +153,26 @@ func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
 		}
 
 		// 解析is_default字段，默认为false
-		isDefault := false
-		if isDefaultStr, hasIsDefault := fields["is_default"]; hasIsDefault {
-			isDefault = isDefaultStr == "1" || strings.ToLower(isDefaultStr) == "true"
+		isDefault := parseBoolField(fields["is_default"])
+
+		// 排序值：order 与 priority 等价，必须为正整数，数值越小越靠前，未配置的排在配置了的之后并保持声明顺序
+		order := fields["order"]
+		if order == "" {
+			order = fields["priority"]
+		}
+		orderValue := 0
+		if order != "" {
+			n, err := strconv.Atoi(order)
+			if err != nil || n < 1 {
+				return fmt.Errorf("视频源 %s 的排序值必须为正整数: %s", code, order)
+			}
+			orderValue = n
+		}
+
+		// enabled 未配置时默认启用
+		enabled := true
+		if v, ok := fields["enabled"]; ok {
+			enabled = parseBoolField(v)
 		}
 
 		source := VideoSource{
@@ -122,6 +180,20 @@ func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
 			Name:      name,
 			URL:       url,
 			IsDefault: isDefault,
+			Adult:     parseBoolField(fields["adult"]),
+			Order:     orderValue,
+			Enabled:   enabled,
+		}
+
+		// 镜像地址按序号排列
+		for n := 2; ; n++ {
+			mirror, ok := fields[fmt.Sprintf("url.%d", n)]
+			if !ok {
+				break
+			}
+			if mirror != "" {
+				source.Mirrors = append(source.Mirrors, mirror)
+			}
 		}
 
 		sc.sources = append(sc.sources, source)
@@ -130,13 +202,56 @@ func (sc *SourcesConfig) LoadFromConfigFile(configData []byte) error {
 	return nil
 }
 
-// GetSources 获取所有视频源
+// parseBoolField 解析 1/true 形式的布尔字段
+func parseBoolField(s string) bool {
+	return s == "1" || strings.ToLower(s) == "true"
+}
+
+// SetBreakerGroup 设置视频源熔断器组
+func (sc *SourcesConfig) SetBreakerGroup(g *BreakerGroup) {
+	sc.breakers = g
+}
+
+// SetContentFilter 设置搜索结果使用的内容过滤器
+func (sc *SourcesConfig) SetContentFilter(cf *ContentFilter) {
+	sc.filter = cf
+}
+
+// GetSources 获取所有视频源（包括已停用的）按排序值排列的快照
 func (sc *SourcesConfig) GetSources() []VideoSource {
-	return sc.sources
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	sources := make([]VideoSource, len(sc.sources))
+	copy(sources, sc.sources)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sortOrder(sources[i].Order) < sortOrder(sources[j].Order)
+	})
+	return sources
+}
+
+// sortOrder 返回用于排序的值，未设置排序值（0）的视频源排在最后
+func sortOrder(order int) int {
+	if order == 0 {
+		return math.MaxInt
+	}
+	return order
+}
+
+// EnabledSources 获取所有启用的视频源
+func (sc *SourcesConfig) EnabledSources() []VideoSource {
+	var enabled []VideoSource
+	for _, source := range sc.GetSources() {
+		if source.Enabled {
+			enabled = append(enabled, source)
+		}
+	}
+	return enabled
 }
 
 // GetSourceByCode 根据代码获取视频源
 func (sc *SourcesConfig) GetSourceByCode(code string) *VideoSource {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
 	for _, source := range sc.sources {
 		if source.Code == code {
 			return &source
@@ -145,6 +260,20 @@ func (sc *SourcesConfig) GetSourceByCode(code string) *VideoSource {
 	return nil
 }
 
+// HasEndpoint 判断接口地址是否为已配置视频源的主地址或镜像地址（包括已停用的视频源）
+func (sc *SourcesConfig) HasEndpoint(api string) bool {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	for i := range sc.sources {
+		for _, endpoint := range sc.sources[i].Endpoints() {
+			if endpoint == api {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // HandleSourcesAPI 处理 /api/sources 接口
 func (sc *SourcesConfig) HandleSourcesAPI(w http.ResponseWriter, r *http.Request) {
 	// 设置CORS头
@@ -164,16 +293,23 @@ func (sc *SourcesConfig) HandleSourcesAPI(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 返回JSON格式的视频源列表
+	// 返回JSON格式的视频源列表，all=1 时包含已停用的视频源
+	sources := sc.EnabledSources()
+	if r.URL.Query().Get("all") == "1" {
+		sources = sc.GetSources()
+	}
+	if sources == nil {
+		sources = []VideoSource{}
+	}
 	response := map[string]interface{}{
 		"success": true,
-		"data":    sc.sources,
-		"count":   len(sc.sources),
+		"data":    sources,
+		"count":   len(sources),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-	log.Printf("✅ /api/sources 请求 [IP:%s]", utils.GetRequestIP(r))
+	sourcesLog.DebugContext(r.Context(), "✅ /api/sources 请求")
 }
 
 // HandleSourceSearchAPI 处理 /api/source_search 接口
@@ -181,7 +317,7 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 	// 设置CORS头
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+AdminPasswordHeader)
 
 	// 处理OPTIONS请求
 	if r.Method == "OPTIONS" {
@@ -230,9 +366,18 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// 之后的日志都带上视频源代码
+	r = r.WithContext(utils.WithLogAttrs(r.Context(), slog.String("source", sourceCode)))
+
+	// source=all 或逗号分隔的多个源时执行聚合搜索
+	if sourceCode == "all" || strings.Contains(sourceCode, ",") {
+		sc.handleAggregatedSearch(w, r, sourceCode, keyword, page)
+		return
+	}
+
 	// 获取指定的视频源
 	source := sc.GetSourceByCode(sourceCode)
-	if source == nil {
+	if source == nil || !source.Enabled {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -244,11 +389,17 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 	}
 
 	// 执行搜索
-	results, err := sc.searchSource(source, keyword, page)
+	results, cacheResult, err := sc.cachedSearch(source, keyword, page)
 	if err != nil {
-		log.Printf("❌ 搜索失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		sourcesLog.WarnContext(r.Context(), "❌ 搜索失败", "error", err)
+		status := http.StatusInternalServerError
+		if openErr, ok := err.(*CircuitOpenError); ok {
+			// 熔断中的源快速失败，提示客户端稍后重试
+			status = http.StatusServiceUnavailable
+			w.Header().Set("Retry-After", strconv.Itoa(int(openErr.RetryAfter.Seconds())+1))
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"message": "Search failed: " + err.Error(),
@@ -257,69 +408,68 @@ func (sc *SourcesConfig) HandleSourceSearchAPI(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// 服务端内容过滤，关键词搜索与最新推荐统一执行
+	filtered := 0
+	if sc.filter != nil && sc.filter.Active(r) {
+		results, filtered = sc.filter.FilterItems(source, results)
+	}
+
 	// 返回搜索结果
 	response := SearchResponse{
-		Success: true,
-		Message: "搜索成功",
-		Data:    results,
-		Count:   len(results),
+		Success:  true,
+		Message:  "搜索成功",
+		Data:     results,
+		Count:    len(results),
+		Filtered: filtered,
 	}
 
+	if cacheResult != nil {
+		WritePrivateCacheHeaders(w, cacheResult)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-	log.Printf("✅ /api/source_search 请求 [IP:%s]", utils.GetRequestIP(r))
+	sourcesLog.DebugContext(r.Context(), "✅ /api/source_search 请求", "count", len(results))
 }
 
-// HandleScorpioSourcesAPI 处理 /api/scorpio_sources 接口，返回 scorpio.json 中的全部内容
-func HandleScorpioSourcesAPI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// searchSource 搜索指定源，依次尝试主地址和镜像地址，跳过熔断中的地址
+func (sc *SourcesConfig) searchSource(source *VideoSource, keyword, page string) ([]VideoItem, error) {
+	var lastErr error
+	var retryAfter time.Duration
+	for _, endpoint := range source.Endpoints() {
+		breaker := sc.breakers.Get(source.Code, endpoint)
+		if ok, wait := breaker.Allow(); !ok {
+			if retryAfter == 0 || wait < retryAfter {
+				retryAfter = wait
+			}
+			continue
+		}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+		videos, err := sc.searchEndpoint(endpoint, keyword, page)
+		if err == nil {
+			breaker.Success()
+			return videos, nil
+		}
+		if breaker.Failure(err) {
+			sourcesLog.Warn("🔌 视频源地址连续失败，已熔断", "source", source.Code, "url", endpoint, "error", err)
+		}
+		lastErr = err
 	}
-	f, err := os.Open("config/scorpio.json")
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "无法读取scorpio.json: " + err.Error(),
-			"data":    nil,
-		})
-		return
+
+	if lastErr != nil {
+		return nil, lastErr
 	}
-	defer f.Close()
-	var sources []map[string]interface{}
-	dec := json.NewDecoder(f)
-	if err := dec.Decode(&sources); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "scorpio.json 解析失败: " + err.Error(),
-			"data":    nil,
-		})
-		return
+	// 所有地址均处于熔断状态
+	openErr := &CircuitOpenError{Code: source.Code, RetryAfter: retryAfter}
+	if st := sc.SourceStatus(source); len(st.Endpoints) > 0 {
+		openErr.LastError = st.Endpoints[0].LastError
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    sources,
-		"count":   len(sources),
-	})
+	return nil, openErr
 }
 
-// searchSource 搜索指定源
-func (sc *SourcesConfig) searchSource(source *VideoSource, keyword, page string) ([]VideoItem, error) {
+// searchEndpoint 请求视频源的单个地址
+func (sc *SourcesConfig) searchEndpoint(endpoint, keyword, page string) ([]VideoItem, error) {
 	// 构建请求URL
-	baseURL := source.URL
+	baseURL := endpoint
 	if !strings.HasSuffix(baseURL, "/") {
 		baseURL += "/"
 	}
@@ -387,14 +537,14 @@ func (sc *SourcesConfig) searchSource(source *VideoSource, keyword, page string)
 	}
 
 	// 添加调试日志
-	log.Printf("🔍 API响应状态: 成功")
+	sourcesLog.Debug("🔍 API响应状态: 成功")
 
 	// 提取视频列表
 	var videos []VideoItem
 
 	// 尝试不同的数据结构
 	if list, ok := result["list"].([]interface{}); ok {
-		log.Printf("✅ 找到list字段，包含 %d 个视频", len(list))
+		sourcesLog.Debug("✅ 找到list字段", "count", len(list))
 		for _, item := range list {
 			if videoMap, ok := item.(map[string]interface{}); ok {
 				video := VideoItem{
@@ -416,7 +566,7 @@ func (sc *SourcesConfig) searchSource(source *VideoSource, keyword, page string)
 			}
 		}
 	} else {
-		log.Printf("❌ 未找到list字段或格式不正确，result keys: %v", getMapKeys(result))
+		sourcesLog.Warn("❌ 未找到list字段或格式不正确", "keys", getMapKeys(result))
 	}
 
 	return videos, nil
@@ -0,0 +1,230 @@
+package components
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 视频源代码格式：由字母、数字、下划线和短横线组成
+var sourceCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+
+// 保存前探测视频源接口的超时时间
+const sourceProbeTimeout = 15 * time.Second
+
+// sourceRequest 添加/修改视频源的请求体，修改时只更新非空字段
+type sourceRequest struct {
+	Name      *string   `json:"name"`
+	URL       *string   `json:"url"`
+	IsDefault *bool     `json:"is_default"`
+	Adult     *bool     `json:"adult"`
+	Order     *int      `json:"order"`
+	Enabled   *bool     `json:"enabled"`
+	Mirrors   *[]string `json:"mirrors"`
+}
+
+// apply 将请求中的字段写入视频源
+func (req *sourceRequest) apply(source *VideoSource) {
+	if req.Name != nil {
+		source.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.URL != nil {
+		source.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.IsDefault != nil {
+		source.IsDefault = *req.IsDefault
+	}
+	if req.Adult != nil {
+		source.Adult = *req.Adult
+	}
+	if req.Order != nil {
+		source.Order = *req.Order
+	}
+	if req.Enabled != nil {
+		source.Enabled = *req.Enabled
+	}
+	if req.Mirrors != nil {
+		source.Mirrors = nil
+		for _, mirror := range *req.Mirrors {
+			if mirror = strings.TrimSpace(mirror); mirror != "" {
+				source.Mirrors = append(source.Mirrors, mirror)
+			}
+		}
+	}
+}
+
+// newEndpoints 返回 next 中相对 current 新增的地址
+func newEndpoints(current, next *VideoSource) []string {
+	known := make(map[string]bool)
+	if current != nil {
+		for _, u := range current.Endpoints() {
+			known[u] = true
+		}
+	}
+	var added []string
+	for _, u := range next.Endpoints() {
+		if !known[u] {
+			added = append(added, u)
+		}
+	}
+	return added
+}
+
+// HandleSourceAdminAPI 处理 /api/sources/{code} 接口
+// GET 查询、POST 添加、PUT 修改、DELETE 移除；新增的接口地址（含镜像）保存前会先探测
+func (sc *SourcesConfig) HandleSourceAdminAPI(w http.ResponseWriter, r *http.Request) {
+	code := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sources/"), "/")
+	if !sourceCodePattern.MatchString(code) {
+		writeSourceError(w, http.StatusBadRequest, "Invalid source code", nil)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		source := sc.GetSourceByCode(code)
+		if source == nil {
+			writeSourceError(w, http.StatusNotFound, "Source not found", nil)
+			return
+		}
+		writeSourceResult(w, http.StatusOK, source)
+	case "POST":
+		sc.createSource(w, r, code)
+	case "PUT":
+		sc.updateSource(w, r, code)
+	case "DELETE":
+		if err := sc.RemoveSource(code); err != nil {
+			writeSourceError(w, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+		sourcesLog.InfoContext(r.Context(), "🗑️ 视频源已移除", "source", code)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// createSource 添加视频源
+func (sc *SourcesConfig) createSource(w http.ResponseWriter, r *http.Request, code string) {
+	req, ok := decodeSourceRequest(w, r)
+	if !ok {
+		return
+	}
+	if sc.GetSourceByCode(code) != nil {
+		writeSourceError(w, http.StatusConflict, "Source already exists", nil)
+		return
+	}
+
+	source := VideoSource{Code: code, Enabled: true, Origin: OriginAPI}
+	req.apply(&source)
+	if source.Name == "" || source.URL == "" {
+		writeSourceError(w, http.StatusBadRequest, "name and url are required", nil)
+		return
+	}
+	for _, u := range newEndpoints(nil, &source) {
+		if !probeSourceURL(w, u) {
+			return
+		}
+	}
+
+	if err := sc.AddSource(source); err != nil {
+		writeSourceError(w, http.StatusConflict, err.Error(), nil)
+		return
+	}
+	writeSourceResult(w, http.StatusCreated, &source)
+	sourcesLog.InfoContext(r.Context(), "➕ 视频源已添加", "source", code, "name", source.Name)
+}
+
+// updateSource 修改视频源，新增的接口地址或镜像地址需先通过探测
+func (sc *SourcesConfig) updateSource(w http.ResponseWriter, r *http.Request, code string) {
+	req, ok := decodeSourceRequest(w, r)
+	if !ok {
+		return
+	}
+	current := sc.GetSourceByCode(code)
+	if current == nil {
+		writeSourceError(w, http.StatusNotFound, "Source not found", nil)
+		return
+	}
+
+	next := *current
+	req.apply(&next)
+	if next.Name == "" || next.URL == "" {
+		writeSourceError(w, http.StatusBadRequest, "name and url must not be empty", nil)
+		return
+	}
+	for _, u := range newEndpoints(current, &next) {
+		if !probeSourceURL(w, u) {
+			return
+		}
+	}
+
+	// 探测期间可能有其他修改，只写入本次请求涉及的字段
+	source, err := sc.UpdateSource(code, req.apply)
+	if err != nil {
+		writeSourceError(w, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+	writeSourceResult(w, http.StatusOK, source)
+	sourcesLog.InfoContext(r.Context(), "✏️ 视频源已修改", "source", code)
+}
+
+// decodeSourceRequest 解析请求体，失败时直接写出 400 响应
+func decodeSourceRequest(w http.ResponseWriter, r *http.Request) (*sourceRequest, bool) {
+	var req sourceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeSourceError(w, http.StatusBadRequest, "Invalid request body", nil)
+		return nil, false
+	}
+	// 0 在内部表示未设置排序值，不允许通过接口写入
+	if req.Order != nil && *req.Order < 1 {
+		writeSourceError(w, http.StatusBadRequest, "order must be a positive integer", nil)
+		return nil, false
+	}
+	return &req, true
+}
+
+// probeSourceURL 探测视频源接口地址，不可用时写出 422 响应并返回 false
+func probeSourceURL(w http.ResponseWriter, api string) bool {
+	u, err := url.Parse(api)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeSourceError(w, http.StatusBadRequest, "Invalid source url", nil)
+		return false
+	}
+	verdict := ValidateMacCMSSource(api, ValidateOptions{Timeout: sourceProbeTimeout})
+	if !verdict.Valid {
+		writeSourceError(w, http.StatusUnprocessableEntity, "Source probe failed: "+verdict.Summary(), verdict)
+		return false
+	}
+	return true
+}
+
+// writeSourceResult 写出单个视频源
+func writeSourceResult(w http.ResponseWriter, status int, source *VideoSource) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    source,
+	})
+}
+
+// writeSourceError 写出错误响应，verdict 不为空时附带探测结论
+func writeSourceError(w http.ResponseWriter, status int, message string, verdict *SourceVerdict) {
+	response := map[string]interface{}{
+		"success": false,
+		"message": message,
+		"data":    nil,
+	}
+	if verdict != nil {
+		response["verdict"] = verdict
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,116 @@
+package components
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleSourceAdminAPI(t *testing.T) {
+	good := newFakeMacCMS(t, fakeMacCMS{})
+	mirror := newFakeMacCMS(t, fakeMacCMS{})
+	bad := newFakeMacCMS(t, fakeMacCMS{list: `{"code":1,"pagecount":0,"list":[]}`})
+	sc := newTestSourcesConfig(t, filepath.Join(t.TempDir(), "sources_local.json"))
+
+	tests := []struct {
+		name       string
+		method     string
+		code       string
+		body       string
+		wantStatus int
+	}{
+		{"无效的代码", "GET", "a.b", "", http.StatusBadRequest},
+		{"代码过长", "GET", strings.Repeat("a", 33), "", http.StatusBadRequest},
+		{"查询不存在的视频源", "GET", "none", "", http.StatusNotFound},
+		{"查询视频源", "GET", "a", "", http.StatusOK},
+		{"请求体无效", "POST", "new", `{`, http.StatusBadRequest},
+		{"缺少名称", "POST", "new", `{"url":"` + good + `"}`, http.StatusBadRequest},
+		{"缺少地址", "POST", "new", `{"name":"新源"}`, http.StatusBadRequest},
+		{"地址协议无效", "POST", "new", `{"name":"新源","url":"ftp://a.example.com/"}`, http.StatusBadRequest},
+		{"地址探测失败", "POST", "new", `{"name":"新源","url":"` + bad + `"}`, http.StatusUnprocessableEntity},
+		{"镜像探测失败", "POST", "new", `{"name":"新源","url":"` + good + `","mirrors":["` + bad + `"]}`, http.StatusUnprocessableEntity},
+		{"添加视频源", "POST", "new", `{"name":"新源","url":"` + good + `","mirrors":["` + mirror + `"]}`, http.StatusCreated},
+		{"代码已存在", "POST", "new", `{"name":"新源","url":"` + good + `"}`, http.StatusConflict},
+		{"修改不存在的视频源", "PUT", "none", `{"name":"x"}`, http.StatusNotFound},
+		{"名称不能改为空", "PUT", "new", `{"name":" "}`, http.StatusBadRequest},
+		{"新增的地址需要探测", "PUT", "new", `{"url":"` + bad + `"}`, http.StatusUnprocessableEntity},
+		{"排序值不能为 0", "PUT", "a", `{"order":0}`, http.StatusBadRequest},
+		{"排序值不能为负数", "POST", "neg", `{"name":"新源","url":"` + good + `","order":-1}`, http.StatusBadRequest},
+		{"修改名称不探测", "PUT", "a", `{"name":"改名","enabled":false}`, http.StatusOK},
+		{"移除视频源", "DELETE", "new", "", http.StatusOK},
+		{"移除不存在的视频源", "DELETE", "new", "", http.StatusNotFound},
+		{"不支持的方法", "PATCH", "a", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, "/api/sources/"+tt.code, strings.NewReader(tt.body))
+		sc.HandleSourceAdminAPI(w, r)
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.wantStatus, w.Body.String())
+		}
+		if tt.wantStatus == http.StatusUnprocessableEntity {
+			var resp struct {
+				Verdict *SourceVerdict `json:"verdict"`
+			}
+			if json.Unmarshal(w.Body.Bytes(), &resp); resp.Verdict == nil || resp.Verdict.Valid {
+				t.Errorf("%s: 探测失败时应返回检测结论: %s", tt.name, w.Body.String())
+			}
+		}
+	}
+
+	if s := sc.GetSourceByCode("a"); s.Name != "改名" || s.Enabled || s.URL != "https://a.example.com/" {
+		t.Errorf("修改后的视频源 = %+v", s)
+	}
+	if sc.GetSourceByCode("new") != nil {
+		t.Error("移除后视频源仍然存在")
+	}
+}
+
+func TestSourceRequestApply(t *testing.T) {
+	var req sourceRequest
+	body := `{"name":" 新名称 ","mirrors":[" https://m1.example.com/ ",""," "],"order":3}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	source := VideoSource{Code: "a", Name: "旧名称", URL: "https://a.example.com/", Enabled: true, Mirrors: []string{"https://old.example.com/"}}
+	req.apply(&source)
+	if source.Name != "新名称" || source.URL != "https://a.example.com/" || !source.Enabled || source.Order != 3 {
+		t.Errorf("apply() = %+v", source)
+	}
+	if len(source.Mirrors) != 1 || source.Mirrors[0] != "https://m1.example.com/" {
+		t.Errorf("mirrors = %v", source.Mirrors)
+	}
+
+	current := VideoSource{URL: "https://a.example.com/", Mirrors: []string{"https://m1.example.com/"}}
+	next := VideoSource{URL: "https://a.example.com/", Mirrors: []string{"https://m1.example.com/", "https://m2.example.com/"}}
+	if added := newEndpoints(&current, &next); len(added) != 1 || added[0] != "https://m2.example.com/" {
+		t.Errorf("newEndpoints() = %v", added)
+	}
+}
+
+func TestSourcesHasEndpoint(t *testing.T) {
+	sc := NewSourcesConfig()
+	config := "[sources]\na.name = 源 A\na.url = https://a.example.com/\na.url.2 = https://m.example.com/\nb.name = 源 B\nb.url = https://b.example.com/\nb.enabled = false\n"
+	if err := sc.LoadFromConfigFile([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		api  string
+		want bool
+	}{
+		{"主地址", "https://a.example.com/", true},
+		{"镜像地址", "https://m.example.com/", true},
+		{"已停用的视频源", "https://b.example.com/", true},
+		{"未配置的地址", "https://evil.example.com/", false},
+		{"空地址", "", false},
+	}
+	for _, tt := range tests {
+		if got := sc.HasEndpoint(tt.api); got != tt.want {
+			t.Errorf("%s: HasEndpoint(%q) = %v, want %v", tt.name, tt.api, got, tt.want)
+		}
+	}
+}
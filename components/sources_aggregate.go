@@ -0,0 +1,99 @@
+package components
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// resolveSearchSources 解析聚合搜索的源列表，结果按视频源排序排列
+// spec 为 all 时返回全部启用的源，否则返回逗号分隔列表中存在且启用的源
+func (sc *SourcesConfig) resolveSearchSources(spec string) []VideoSource {
+	enabled := sc.EnabledSources()
+	if spec == "all" {
+		return enabled
+	}
+
+	wanted := make(map[string]bool)
+	for _, code := range strings.Split(spec, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			wanted[code] = true
+		}
+	}
+	var sources []VideoSource
+	for _, source := range enabled {
+		if wanted[source.Code] {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// handleAggregatedSearch 并发搜索多个源，按视频源排序合并结果
+// 每个结果标记来源代码和名称，单个源失败不影响其他源，失败原因记录在 errors 中
+func (sc *SourcesConfig) handleAggregatedSearch(w http.ResponseWriter, r *http.Request, spec, keyword, page string) {
+	sources := sc.resolveSearchSources(spec)
+	if len(sources) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Source not found",
+			"data":    []VideoItem{},
+		})
+		return
+	}
+
+	results := make([][]VideoItem, len(sources))
+	errs := make([]error, len(sources))
+	cacheResults := make([]*CacheResult, len(sources))
+	var wg sync.WaitGroup
+	for i := range sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], cacheResults[i], errs[i] = sc.cachedSearch(&sources[i], keyword, page)
+		}(i)
+	}
+	wg.Wait()
+
+	filterActive := sc.filter != nil && sc.filter.Active(r)
+	response := SearchResponse{
+		Success: true,
+		Message: "搜索成功",
+		Data:    []VideoItem{},
+	}
+	for i, source := range sources {
+		if errs[i] != nil {
+			if response.Errors == nil {
+				response.Errors = make(map[string]string)
+			}
+			response.Errors[source.Code] = errs[i].Error()
+			continue
+		}
+		items := results[i]
+		if filterActive {
+			var filtered int
+			items, filtered = sc.filter.FilterItems(&sources[i], items)
+			response.Filtered += filtered
+		}
+		for _, item := range items {
+			item.SourceCode = source.Code
+			item.SourceName = source.Name
+			response.Data = append(response.Data, item)
+		}
+	}
+	response.Count = len(response.Data)
+	if len(response.Errors) == len(sources) {
+		response.Success = false
+		response.Message = "所有源搜索失败"
+	}
+
+	if merged := mergeCacheResults(cacheResults); merged != nil {
+		WritePrivateCacheHeaders(w, merged)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+	sourcesLog.DebugContext(r.Context(), "✅ /api/source_search 聚合搜索", "sources", len(sources))
+}
@@ -0,0 +1,92 @@
+package components
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// searchCache 搜索结果缓存及其策略
+type searchCache struct {
+	cache  *ResponseCache
+	search CachePolicy // 关键词搜索
+	latest CachePolicy // 最新推荐
+}
+
+// SetSearchCache 设置搜索结果缓存，search 与 latest 分别为关键词搜索和最新推荐的缓存策略
+func (sc *SourcesConfig) SetSearchCache(cache *ResponseCache, search, latest CachePolicy) {
+	sc.cache = &searchCache{cache: cache, search: search, latest: latest}
+}
+
+// cachedSearch 通过缓存搜索指定源，未设置缓存时直接请求上游且返回的 CacheResult 为 nil
+// 缓存保存上游原始结果，内容过滤在读取后按请求执行，因此缓存键只包含源、关键词和页码；
+// 源的地址和镜像也计入缓存键，修改地址后不会返回旧地址的缓存
+func (sc *SourcesConfig) cachedSearch(source *VideoSource, keyword, page string) ([]VideoItem, *CacheResult, error) {
+	if sc.cache == nil {
+		videos, err := sc.searchSource(source, keyword, page)
+		return videos, nil, err
+	}
+
+	policy := sc.cache.search
+	if keyword == "" {
+		policy = sc.cache.latest
+	}
+	if page == "" {
+		page = "1"
+	}
+	key := strings.Join([]string{"search", source.Code, strings.Join(source.Endpoints(), ","), keyword, page}, "|")
+
+	res, err := sc.cache.cache.Do(key, policy, func() ([]byte, error) {
+		videos, err := sc.searchSource(source, keyword, page)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(videos)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var videos []VideoItem
+	if err := json.Unmarshal(res.Value, &videos); err != nil {
+		return nil, nil, err
+	}
+	return videos, res, nil
+}
+
+// mergeCacheResults 合并聚合搜索中各源的缓存状态：
+// 任一源请求了上游即为 MISS，否则任一源为过期缓存即为 STALE；剩余新鲜期取最小值，缓存时长取最大值
+func mergeCacheResults(results []*CacheResult) *CacheResult {
+	var merged *CacheResult
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		if merged == nil {
+			copied := *res
+			merged = &copied
+			continue
+		}
+		if res.Status == CacheMiss || (res.Status == CacheStale && merged.Status == CacheHit) {
+			merged.Status = res.Status
+		}
+		if res.MaxAge < merged.MaxAge {
+			merged.MaxAge = res.MaxAge
+		}
+		if res.Age > merged.Age {
+			merged.Age = res.Age
+		}
+		if res.Policy.StaleWhileRevalidate < merged.Policy.StaleWhileRevalidate {
+			merged.Policy.StaleWhileRevalidate = res.Policy.StaleWhileRevalidate
+		}
+	}
+	return merged
+}
+
+// SecondsPolicy 根据秒数构造缓存策略
+func SecondsPolicy(ttl, staleWhileRevalidate, staleIfError int) CachePolicy {
+	return CachePolicy{
+		TTL:                  time.Duration(ttl) * time.Second,
+		StaleWhileRevalidate: time.Duration(staleWhileRevalidate) * time.Second,
+		StaleIfError:         time.Duration(staleIfError) * time.Second,
+	}
+}
@@ -0,0 +1,60 @@
+package components
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestListServer 启动固定返回一个影片的 MacCMS 列表接口
+func newTestListServer(t *testing.T, name string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"code":1,"list":[{"vod_name":%q}]}`, name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/"
+}
+
+func TestCachedSearchEndpointChange(t *testing.T) {
+	oldURL := newTestListServer(t, "旧地址")
+	newURL := newTestListServer(t, "新地址")
+
+	sc := NewSourcesConfig()
+	if err := sc.LoadFromConfigFile([]byte("[sources]\na.name = 源 A\na.url = " + oldURL + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	sc.SetSearchCache(newTestCache(t, ""), testCachePolicy, testCachePolicy)
+
+	search := func() (string, string) {
+		videos, res, err := sc.cachedSearch(sc.GetSourceByCode("a"), "片", "")
+		if err != nil || len(videos) != 1 {
+			t.Fatalf("cachedSearch() = %v, %v", videos, err)
+		}
+		return videos[0].VodName, res.Status
+	}
+
+	steps := []struct {
+		name       string
+		update     func(s *VideoSource)
+		wantName   string
+		wantStatus string
+	}{
+		{"首次搜索", nil, "旧地址", CacheMiss},
+		{"再次搜索", nil, "旧地址", CacheHit},
+		{"修改地址后", func(s *VideoSource) { s.URL = newURL }, "新地址", CacheMiss},
+		{"添加镜像后", func(s *VideoSource) { s.Mirrors = []string{oldURL} }, "新地址", CacheMiss},
+		{"地址不变时", nil, "新地址", CacheHit},
+	}
+	for _, step := range steps {
+		if step.update != nil {
+			if _, err := sc.UpdateSource("a", step.update); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if name, status := search(); name != step.wantName || status != step.wantStatus {
+			t.Errorf("%s: 结果 = %s %s, want %s %s", step.name, name, status, step.wantName, step.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SourcesLocalPath 运行时视频源默认保存路径
+const SourcesLocalPath = "config/sources_local.json"
+
+// 视频源来源
+const (
+	OriginAPI    = "api"    // 通过 /api/sources/{code} 添加
+	OriginConfig = "config" // 来自 config.ini，但在运行时被修改过
+)
+
+// localSourcesFile 运行时视频源文件格式
+type localSourcesFile struct {
+	Sources []VideoSource `json:"sources"`           // 运行时添加或修改过的视频源
+	Removed []string      `json:"removed,omitempty"` // 运行时移除的 config.ini 视频源代码
+}
+
+// LoadLocalSources 加载运行时添加或修改的视频源，并将 path 作为之后的保存路径
+// 文件不存在时视为没有运行时修改；需在 LoadFromConfigFile 之后调用
+func (sc *SourcesConfig) LoadLocalSources(path string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.localPath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var local localSourcesFile
+	if err := json.Unmarshal(data, &local); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	for _, code := range local.Removed {
+		if idx := sc.indexLocked(code); idx >= 0 {
+			sc.sources = append(sc.sources[:idx], sc.sources[idx+1:]...)
+		}
+		sc.removed[code] = true
+	}
+	for _, source := range local.Sources {
+		if source.Code == "" || source.URL == "" {
+			continue
+		}
+		idx := sc.indexLocked(source.Code)
+		switch {
+		case idx < 0:
+			sc.sources = append(sc.sources, source)
+		case source.Origin == OriginConfig:
+			// 覆盖 config.ini 中的同名视频源
+			sc.sources[idx] = source
+		}
+	}
+	return nil
+}
+
+// indexLocked 返回指定代码的视频源下标，不存在时返回 -1，调用方需持有锁
+func (sc *SourcesConfig) indexLocked(code string) int {
+	for i, source := range sc.sources {
+		if source.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveLocalSourcesLocked 将运行时的修改写回文件，调用方需持有锁
+func (sc *SourcesConfig) saveLocalSourcesLocked() error {
+	if sc.localPath == "" {
+		return nil
+	}
+	local := localSourcesFile{Sources: []VideoSource{}}
+	for _, source := range sc.sources {
+		if source.Origin != "" {
+			local.Sources = append(local.Sources, source)
+		}
+	}
+	for code := range sc.removed {
+		local.Removed = append(local.Removed, code)
+	}
+	sort.Strings(local.Removed)
+	data, err := json.MarshalIndent(local, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免写入中途失败损坏原文件
+	tmp := sc.localPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sc.localPath)
+}
+
+// AddSource 添加一个运行时视频源并持久化，代码已存在时返回错误
+func (sc *SourcesConfig) AddSource(source VideoSource) error {
+	if source.Origin == "" {
+		return fmt.Errorf("运行时视频源必须指定来源")
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.indexLocked(source.Code) >= 0 {
+		return fmt.Errorf("视频源 %s 已存在", source.Code)
+	}
+	sc.sources = append(sc.sources, source)
+	return sc.saveLocalSourcesLocked()
+}
+
+// UpdateSource 在锁内对指定视频源执行 update 并持久化，返回更新后的视频源
+// 修改 config.ini 中的视频源时将其标记为 OriginConfig，以便重启后保留修改
+func (sc *SourcesConfig) UpdateSource(code string, update func(source *VideoSource)) (*VideoSource, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	idx := sc.indexLocked(code)
+	if idx < 0 {
+		return nil, fmt.Errorf("视频源 %s 不存在", code)
+	}
+	source := sc.sources[idx]
+	update(&source)
+	source.Code = code
+	if source.Origin == "" {
+		source.Origin = OriginConfig
+	}
+	sc.sources[idx] = source
+	if err := sc.saveLocalSourcesLocked(); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// RemoveSource 移除一个视频源并持久化
+// config.ini 中的视频源会记录到移除列表中，重启后依然不会加载
+func (sc *SourcesConfig) RemoveSource(code string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	idx := sc.indexLocked(code)
+	if idx < 0 {
+		return fmt.Errorf("视频源 %s 不存在", code)
+	}
+	origin := sc.sources[idx].Origin
+	if origin == "" || origin == OriginConfig {
+		sc.removed[code] = true
+	}
+	sc.sources = append(sc.sources[:idx], sc.sources[idx+1:]...)
+	return sc.saveLocalSourcesLocked()
+}
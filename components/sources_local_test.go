@@ -0,0 +1,122 @@
+package components
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSourcesConfig = `
+[sources]
+a.name = 源 A
+a.url = https://a.example.com/
+b.name = 源 B
+b.url = https://b.example.com/
+`
+
+// newTestSourcesConfig 加载测试配置，运行时修改保存在 path
+func newTestSourcesConfig(t *testing.T, path string) *SourcesConfig {
+	t.Helper()
+	sc := NewSourcesConfig()
+	if err := sc.LoadFromConfigFile([]byte(testSourcesConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.LoadLocalSources(path); err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+// sourceNames 返回视频源代码与名称，便于比较
+func sourceNames(sc *SourcesConfig) string {
+	var list []string
+	for _, s := range sc.GetSources() {
+		list = append(list, s.Code+"="+s.Name)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestLocalSourcesPersistence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sources_local.json")
+	sc := newTestSourcesConfig(t, path)
+
+	steps := []struct {
+		name string
+		op   func() error
+		want string
+	}{
+		{"添加运行时视频源", func() error {
+			return sc.AddSource(VideoSource{Code: "c", Name: "源 C", URL: "https://c.example.com/", Enabled: true, Origin: OriginAPI})
+		}, "a=源 A,b=源 B,c=源 C"},
+		{"修改 config.ini 视频源", func() error {
+			_, err := sc.UpdateSource("a", func(s *VideoSource) { s.Name = "源 A2" })
+			return err
+		}, "a=源 A2,b=源 B,c=源 C"},
+		{"移除 config.ini 视频源", func() error { return sc.RemoveSource("b") }, "a=源 A2,c=源 C"},
+	}
+	for _, step := range steps {
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := sourceNames(sc); got != step.want {
+			t.Fatalf("%s: 视频源 = %s, want %s", step.name, got, step.want)
+		}
+
+		// 每次修改都完整写回文件，不残留临时文件
+		if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+			t.Errorf("%s: 残留临时文件: %v", step.name, err)
+		}
+		var local localSourcesFile
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &local); err != nil {
+			t.Fatalf("%s: 保存的文件不是有效的 JSON: %v", step.name, err)
+		}
+
+		// 重启后恢复相同的状态
+		if got := sourceNames(newTestSourcesConfig(t, path)); got != step.want {
+			t.Errorf("%s: 重新加载后视频源 = %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	if s := sc.GetSourceByCode("a"); s.Origin != OriginConfig {
+		t.Errorf("修改过的 config.ini 视频源 origin = %q, want %q", s.Origin, OriginConfig)
+	}
+	if err := sc.AddSource(VideoSource{Code: "c", Name: "重复", URL: "https://c.example.com/", Origin: OriginAPI}); err == nil {
+		t.Error("重复的代码应返回错误")
+	}
+	if err := sc.AddSource(VideoSource{Code: "d", Name: "源 D", URL: "https://d.example.com/"}); err == nil {
+		t.Error("未指定来源应返回错误")
+	}
+	if err := sc.RemoveSource("missing"); err == nil {
+		t.Error("移除不存在的视频源应返回错误")
+	}
+}
+
+func TestLoadLocalSourcesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sources_local.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sc := NewSourcesConfig()
+	if err := sc.LoadLocalSources(path); err == nil {
+		t.Error("无效的文件应返回错误")
+	}
+	if err := NewSourcesConfig().LoadLocalSources(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("文件不存在时 LoadLocalSources() = %v, want nil", err)
+	}
+}
+
+func TestSaveLocalSourcesFailure(t *testing.T) {
+	// 保存路径的目录不存在时写入失败
+	sc := newTestSourcesConfig(t, filepath.Join(t.TempDir(), "missing", "sources_local.json"))
+	err := sc.AddSource(VideoSource{Code: "c", Name: "源 C", URL: "https://c.example.com/", Origin: OriginAPI})
+	if err == nil {
+		t.Error("无法写入时应返回错误")
+	}
+}
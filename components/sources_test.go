@@ -0,0 +1,33 @@
+package components
+
+import "testing"
+
+func TestLoadFromConfigFileOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr bool
+	}{
+		{"按排序值排列，未设置的在后并保持声明顺序",
+			"[sources]\na.name = A\na.url = https://a/\nb.name = B\nb.url = https://b/\nb.order = 2\nc.name = C\nc.url = https://c/\nc.priority = 1\nd.name = D\nd.url = https://d/\n",
+			"c=C,b=B,a=A,d=D", false},
+		{"order 优先于 priority",
+			"[sources]\na.name = A\na.url = https://a/\na.order = 3\na.priority = 1\nb.name = B\nb.url = https://b/\nb.order = 2\n",
+			"b=B,a=A", false},
+		{"排序值为 0", "[sources]\na.name = A\na.url = https://a/\na.order = 0\n", "", true},
+		{"排序值为负数", "[sources]\na.name = A\na.url = https://a/\na.priority = -1\n", "", true},
+		{"排序值不是整数", "[sources]\na.name = A\na.url = https://a/\na.order = first\n", "", true},
+	}
+	for _, tt := range tests {
+		sc := NewSourcesConfig()
+		err := sc.LoadFromConfigFile([]byte(tt.config))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: LoadFromConfigFile() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got := sourceNames(sc); !tt.wantErr && got != tt.want {
+			t.Errorf("%s: 视频源 = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,11 @@ module vastproxy-go
 
 go 1.21
 
-require gopkg.in/ini.v1 v1.67.0
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/image v0.18.0
+	golang.org/x/time v0.5.0
+	gopkg.in/ini.v1 v1.67.0
+)
 
 require github.com/stretchr/testify v1.10.0 // indirect
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 端口被占用时的处理方式，对应 [server] port_fallback
+const (
+	PortFallbackFail = "fail" // 报错退出，并给出占用端口的进程
+	PortFallbackNext = "next" // 依次尝试后续端口
+	PortFallbackAuto = "auto" // 由系统分配空闲端口
+)
+
+// 端口回退时最多尝试的后续端口数量
+const maxPortAttempts = 20
+
+// systemd 套接字激活传入的第一个文件描述符
+const listenFDsStart = 3
+
+// listenOptions 监听参数
+type listenOptions struct {
+	Host       string
+	Port       string
+	Fallback   string
+	KillPort   bool   // 端口被占用时结束占用进程（需显式开启）
+	UnixSocket string // 不为空时监听 Unix socket，忽略 Host/Port
+}
+
+// openListener 按优先级打开监听：systemd 套接字激活 > Unix socket > TCP 端口
+func openListener(opts listenOptions) (net.Listener, error) {
+	if ln, err := activationListener(); ln != nil || err != nil {
+		return ln, err
+	}
+	if opts.UnixSocket != "" {
+		return listenUnix(opts.UnixSocket)
+	}
+	return listenTCP(opts)
+}
+
+// activationListener 读取 systemd 套接字激活（LISTEN_PID/LISTEN_FDS）传入的监听，未激活时返回 nil
+func activationListener() (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	// 只使用第一个套接字，并清除环境变量避免子进程误用
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if n > 1 {
+		serverLog.Warn("⚠️ 套接字激活传入多个监听，仅使用第一个", "count", n)
+	}
+	f := os.NewFile(uintptr(listenFDsStart), "LISTEN_FD_3")
+	ln, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("使用套接字激活传入的监听失败: %v", err)
+	}
+	serverLog.Info("🔌 使用 systemd 套接字激活", "addr", ln.Addr().String())
+	return ln, nil
+}
+
+// listenUnix 监听 Unix socket，路径上残留的无人监听的 socket 文件会被清理
+func listenUnix(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("Unix socket %s 已有其他进程在监听", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("清理残留的 Unix socket %s 失败: %v", path, err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听 Unix socket %s 失败: %v", path, err)
+	}
+	return ln, nil
+}
+
+// listenTCP 监听 TCP 端口，端口被占用时按 Fallback 处理
+func listenTCP(opts listenOptions) (net.Listener, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(opts.Host, opts.Port))
+	if err == nil || !isAddrInUse(err) {
+		return ln, err
+	}
+
+	owners := portOwners(opts.Port)
+	if opts.KillPort {
+		serverLog.Warn("⚠️ 端口被占用，-kill-port 已开启，正在结束占用进程", "port", opts.Port, "owners", describeOwners(owners))
+		if err := killPortOwners(owners); err != nil {
+			return nil, err
+		}
+		time.Sleep(500 * time.Millisecond)
+		ln, err = net.Listen("tcp", net.JoinHostPort(opts.Host, opts.Port))
+		if err != nil {
+			return nil, fmt.Errorf("端口 %s 仍然被占用: %v", opts.Port, err)
+		}
+		serverLog.Info("✅ 端口已释放并可用", "port", opts.Port)
+		return ln, nil
+	}
+
+	switch opts.Fallback {
+	case PortFallbackNext:
+		base, convErr := strconv.Atoi(opts.Port)
+		if convErr != nil {
+			return nil, fmt.Errorf("无效的端口: %s", opts.Port)
+		}
+		for p := base + 1; p <= base+maxPortAttempts && p <= 65535; p++ {
+			ln, err = net.Listen("tcp", net.JoinHostPort(opts.Host, strconv.Itoa(p)))
+			if err == nil {
+				serverLog.Warn("⚠️ 端口被占用，改用后续端口", "port", opts.Port, "owners", describeOwners(owners), "fallback_port", p)
+				return ln, nil
+			}
+		}
+		return nil, fmt.Errorf("端口 %s 被占用 (%s)，且后续 %d 个端口均不可用", opts.Port, describeOwners(owners), maxPortAttempts)
+	case PortFallbackAuto:
+		ln, err = net.Listen("tcp", net.JoinHostPort(opts.Host, "0"))
+		if err != nil {
+			return nil, err
+		}
+		serverLog.Warn("⚠️ 端口被占用，改用系统分配的端口", "port", opts.Port, "owners", describeOwners(owners), "fallback_port", listenerPort(ln))
+		return ln, nil
+	}
+	return nil, fmt.Errorf("端口 %s 被占用 (%s)，可使用 -port 指定其他端口、在 [server] port_fallback 中配置 next/auto，或使用 -kill-port 结束占用进程",
+		opts.Port, describeOwners(owners))
+}
+
+// isAddrInUse 判断监听失败是否因为地址已被占用
+func isAddrInUse(err error) bool {
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return true
+	}
+	msg := err.Error()
+	// Windows 上为 WSAEADDRINUSE，错误文本为 "Only one usage of each socket address ..."
+	return strings.Contains(msg, "address already in use") || strings.Contains(msg, "Only one usage of each socket address")
+}
+
+// listenerPort 返回 TCP 监听的实际端口，非 TCP 监听返回空字符串
+func listenerPort(ln net.Listener) string {
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok {
+		return strconv.Itoa(addr.Port)
+	}
+	return ""
+}
+
+// portOwner 占用端口的进程
+type portOwner struct {
+	PID  string
+	Name string
+}
+
+// portOwners 查找监听指定端口的进程，查找失败时返回空列表
+func portOwners(port string) []portOwner {
+	switch runtime.GOOS {
+	case "darwin", "linux":
+		return portOwnersUnix(port)
+	case "windows":
+		return portOwnersWindows(port)
+	}
+	return nil
+}
+
+// portOwnersUnix 通过 lsof 查找监听端口的进程
+func portOwnersUnix(port string) []portOwner {
+	output, err := exec.Command("lsof", "-nP", "-iTCP:"+port, "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return nil
+	}
+	// -F 输出格式: 每个进程一行 p<PID>，随后一行 c<命令名>
+	var owners []portOwner
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "p"):
+			owners = append(owners, portOwner{PID: line[1:]})
+		case strings.HasPrefix(line, "c") && len(owners) > 0:
+			owners[len(owners)-1].Name = line[1:]
+		}
+	}
+	return owners
+}
+
+// portOwnersWindows 通过 netstat 和 tasklist 查找监听端口的进程
+func portOwnersWindows(port string) []portOwner {
+	output, err := exec.Command("netstat", "-ano").Output()
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var owners []portOwner
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.Contains(line, "LISTENING") || !strings.HasSuffix(fields[1], ":"+port) {
+			continue
+		}
+		pid := fields[len(fields)-1]
+		if pid == "0" || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		owner := portOwner{PID: pid}
+		// tasklist CSV 输出: "映像名称","PID",...
+		if out, err := exec.Command("tasklist", "/FI", "PID eq "+pid, "/FO", "CSV", "/NH").Output(); err == nil {
+			if name, _, ok := strings.Cut(strings.TrimSpace(string(out)), ","); ok {
+				owner.Name = strings.Trim(name, `"`)
+			}
+		}
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// describeOwners 格式化占用进程列表，用于日志和错误信息
+func describeOwners(owners []portOwner) string {
+	if len(owners) == 0 {
+		return "未能确定占用进程"
+	}
+	parts := make([]string, 0, len(owners))
+	for _, o := range owners {
+		if o.Name != "" {
+			parts = append(parts, fmt.Sprintf("%s PID %s", o.Name, o.PID))
+		} else {
+			parts = append(parts, "PID "+o.PID)
+		}
+	}
+	return "占用进程: " + strings.Join(parts, ", ")
+}
+
+// killPortOwners 强制结束占用端口的进程，仅在 -kill-port 开启时调用
+func killPortOwners(owners []portOwner) error {
+	if len(owners) == 0 {
+		return fmt.Errorf("未找到占用端口的进程，可能端口被系统保留")
+	}
+	for _, o := range owners {
+		serverLog.Warn("🔫 正在结束进程", "pid", o.PID, "name", o.Name)
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("taskkill", "/F", "/PID", o.PID)
+		} else {
+			cmd = exec.Command("kill", "-9", o.PID)
+		}
+		if err := cmd.Run(); err != nil {
+			serverLog.Error("⚠️ 结束进程失败", "pid", o.PID, "error", err)
+		} else {
+			serverLog.Info("✅ 已结束进程", "pid", o.PID)
+		}
+	}
+	return nil
+}
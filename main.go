@@ -1,27 +1,32 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"embed"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 
-	"runtime"
-	"sync"
 	"vastproxy-go/components"
 	"vastproxy-go/utils"
 )
 
+// mainLog 启动流程与页面日志
+var mainLog = utils.Logger("main")
+
+// fatal 记录错误日志并退出
+func fatal(msg string, args ...any) {
+	mainLog.Error(msg, args...)
+	os.Exit(1)
+}
+
 //go:embed html/index_mobile.html html/about.html
 var htmlContent embed.FS
 
@@ -31,64 +36,6 @@ var ConfigContent embed.FS
 //go:embed html/check_sources.html
 var checkSourcesHTML embed.FS
 
-// ScorpioSource 结构体
-// 用于解析和保存 scorpio.json 的每个资源
-// 只在 main.go 内部使用
-
-type ScorpioSource struct {
-	Name          string `json:"name"`
-	API           string `json:"api"`
-	LastCheckTime int64  `json:"last_check_time,omitempty"`
-	IsValid       *bool  `json:"is_valid,omitempty"`
-}
-
-const ScorpioJsonPath = "config/scorpio.json"
-
-// 读取scorpio.json
-func LoadScorpioSources() ([]*ScorpioSource, error) {
-	f, err := os.Open(ScorpioJsonPath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	var sources []*ScorpioSource
-	dec := json.NewDecoder(f)
-	if err := dec.Decode(&sources); err != nil {
-		return nil, err
-	}
-	return sources, nil
-}
-
-// 保存scorpio.json
-func SaveScorpioSources(sources []*ScorpioSource) error {
-	data, err := json.MarshalIndent(sources, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(ScorpioJsonPath, data, 0644)
-}
-
-// 检查单个资源，返回是否可用、消息、JSON内容、响应时间（毫秒）
-func CheckSourceAPIWithBody(api string) (bool, string, interface{}, int64) {
-	start := time.Now()
-	client := &http.Client{Timeout: 15 * time.Second} // 增加最大等待时间
-	resp, err := client.Get(api)
-	cost := time.Since(start).Milliseconds()
-	if err != nil {
-		return false, err.Error(), nil, cost
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		var result interface{}
-		dec := json.NewDecoder(resp.Body)
-		if err := dec.Decode(&result); err == nil {
-			return true, "ok", result, cost
-		}
-		return true, "ok (非JSON)", nil, cost
-	}
-	return false, fmt.Sprintf("HTTP %d", resp.StatusCode), nil, cost
-}
-
 // 资源检测页面
 func checkSourcesPageHandler(w http.ResponseWriter, r *http.Request) {
 	htmlBytes, err := checkSourcesHTML.ReadFile("html/check_sources.html")
@@ -96,7 +43,7 @@ func checkSourcesPageHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "无法读取HTML模板", 500)
 		return
 	}
-	sources, err := LoadScorpioSources()
+	sources := scorpioManager.Sources()
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write(htmlBytes)
 	fmt.Fprintf(w, `<script>\nwindow._scorpio_sources = %s;\n</script>`, mustJson(sources))
@@ -107,59 +54,6 @@ func mustJson(v interface{}) string {
 	return string(b)
 }
 
-// SSE流接口
-func checkSourcesStreamHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	sources, err := LoadScorpioSources()
-	if err != nil {
-		fmt.Fprintf(w, "data: {\"msg\":\"读取scorpio.json失败\"}\n\n")
-		w.(http.Flusher).Flush()
-		return
-	}
-
-	var mu sync.Mutex
-	for i, src := range sources {
-		go func(idx int, s *ScorpioSource) {
-			isValid, msg, result, cost := CheckSourceAPIWithBody(s.API)
-			t := time.Now().Unix()
-			// 响应时间评级
-			level := "快"
-			if cost > 8000 {
-				level = "慢"
-			} else if cost > 3000 {
-				level = "中"
-			}
-			mu.Lock()
-			s.LastCheckTime = t
-			s.IsValid = &isValid
-			SaveScorpioSources(sources)
-			mu.Unlock()
-			res := map[string]interface{}{
-				"index":           idx,
-				"name":            s.Name,
-				"api":             s.API,
-				"last_check_time": t,
-				"is_valid":        isValid,
-				"msg":             msg,
-				"response_time":   cost,
-				"response_level":  level,
-			}
-			if isValid && result != nil {
-				res["result_json"] = result
-			}
-			b, _ := json.Marshal(res)
-			fmt.Fprintf(w, "data: %s\n\n", b)
-			w.(http.Flusher).Flush()
-		}(i, src)
-	}
-
-	// 等待所有goroutine完成
-	time.Sleep(time.Duration(len(sources)) * 16 * time.Second)
-}
-
 // 检查单个资源API（前端逐个调用）
 func HandleCheckSourceAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -182,83 +76,351 @@ func HandleCheckSourceAPI(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	isValid, msg, result, cost := CheckSourceAPIWithBody(api)
-	level := "快"
-	if cost > 8000 {
-		level = "慢"
-	} else if cost > 3000 {
-		level = "中"
-	}
+	verdict := components.ValidateMacCMSSource(api, components.ValidateOptions{
+		SampleKeyword: r.URL.Query().Get("keyword"),
+		ProbePlayURL:  r.URL.Query().Get("probe") == "1",
+	})
 	resp := map[string]interface{}{
 		"success":        true,
-		"is_valid":       isValid && result != nil,
-		"msg":            msg,
-		"response_time":  cost,
-		"response_level": level,
+		"is_valid":       verdict.Valid,
+		"verdict":        verdict,
+		"response_time":  verdict.LatencyMs,
+		"response_level": components.ResponseLevel(verdict.LatencyMs),
 	}
-	if isValid && result != nil {
-		resp["result_json"] = result
+	if verdict.Valid && verdict.Sample != nil {
+		resp["result_json"] = verdict.Sample
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// checkSourceHandler 包装 /api/check_source 的访问控制
+// 检测会让服务端请求调用方提供的地址，未登录时只允许检测已配置的视频源或 scorpio 资源，
+// 其他地址以及 probe=1（请求视频源返回的任意播放地址）仅限管理员使用
+func checkSourceHandler(auth *components.AuthManager, known func(api string) bool) http.HandlerFunc {
+	adminCheck := auth.RequireAdmin(HandleCheckSourceAPI)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("probe") != "1" && known(query.Get("api")) {
+			HandleCheckSourceAPI(w, r)
+			return
+		}
+		adminCheck(w, r)
+	}
+}
+
+// responseCacheMaxAge 返回响应缓存磁盘文件的保留时长
+// 不短于已启用的缓存策略中旧数据仍可返回的最长时长，避免 stale_if_error 期间的缓存被提前清理
+func responseCacheMaxAge(config *utils.Config) time.Duration {
+	maxAge := time.Duration(config.Cache.DiskMaxAgeHours) * time.Hour
+	if maxAge <= 0 {
+		return 0
+	}
+	var policies []components.CachePolicy
+	if c := config.Cache; c.Enabled {
+		for _, ttl := range []int{c.SearchTTLSeconds, c.LatestTTLSeconds, c.MatchTTLSeconds} {
+			policies = append(policies, components.SecondsPolicy(ttl, c.StaleWhileRevalidateSeconds, c.StaleIfErrorSeconds))
+		}
+	}
+	if d := config.Douban; d.CacheEnabled {
+		for _, ttl := range []int{d.TagsTTLSeconds, d.SubjectsTTLSeconds, d.DetailTTLSeconds, d.SuggestTTLSeconds, d.ChartTTLSeconds} {
+			policies = append(policies, components.SecondsPolicy(ttl, d.StaleWhileRevalidateSeconds, d.StaleIfErrorSeconds))
+		}
+	}
+	retention := maxAge
+	for _, p := range policies {
+		if r := p.Retention(); r > retention {
+			retention = r
+		}
+	}
+	if retention > maxAge {
+		mainLog.Warn("⚠️ disk_max_age_hours 短于缓存可返回旧数据的时长，已延长", "disk_max_age_hours", config.Cache.DiskMaxAgeHours, "max_age", retention.String())
+	}
+	return retention
+}
+
 var GlobalConfig *utils.Config
 
+// scorpioManager scorpio.json 资源管理器
+var scorpioManager *components.ScorpioManager
+
 func main() {
 	// 加载配置文件
 	if err := LoadConfig(); err != nil {
-		log.Fatalf("❌ 加载配置文件失败: %v", err)
+		fatal("❌ 加载配置文件失败", "error", err)
+	}
+	if err := utils.SetTrustedProxies(GlobalConfig.Security.TrustedProxies); err != nil {
+		fatal("❌ 解析受信任代理列表失败", "error", err)
 	}
 
 	// 初始化视频源配置
 	sourcesConfig := components.NewSourcesConfig()
 	configData, err := ConfigContent.ReadFile("config/config.ini")
 	if err != nil {
-		log.Fatalf("❌ 读取配置文件失败: %v", err)
+		fatal("❌ 读取配置文件失败", "error", err)
 	}
 	if err := sourcesConfig.LoadFromConfigFile(configData); err != nil {
-		log.Fatalf("❌ 加载视频源配置失败: %v", err)
+		fatal("❌ 加载视频源配置失败", "error", err)
+	}
+	localSourcesPath := GlobalConfig.Sources.LocalFile
+	if localSourcesPath == "" {
+		localSourcesPath = components.SourcesLocalPath
 	}
-	log.Printf("✅ 视频源配置加载成功，共 %d 个源", len(sourcesConfig.GetSources()))
+	if err := sourcesConfig.LoadLocalSources(localSourcesPath); err != nil {
+		mainLog.Warn("⚠️ 读取本地视频源失败", "file", localSourcesPath, "error", err)
+	}
+	mainLog.Info("✅ 视频源配置加载成功", "count", len(sourcesConfig.GetSources()))
 
 	// 定义命令行参数
 	var (
-		port = flag.String("port", GlobalConfig.Server.Port, "服务端口")
+		port         = flag.String("port", GlobalConfig.Server.Port, "服务端口")
+		unixSocket   = flag.String("unix-socket", GlobalConfig.Server.UnixSocket, "监听 Unix socket 路径，设置后忽略端口")
+		killPort     = flag.Bool("kill-port", false, "端口被占用时强制结束占用进程")
+		hashPassword = flag.Bool("hash-password", false, "从标准输入读取管理员密码，输出 bcrypt 哈希并退出")
 	)
 	flag.Parse()
 
-	// 设置日志输出
+	if *hashPassword {
+		// 从标准输入读取密码，避免出现在进程列表和 shell 历史中
+		fmt.Fprint(os.Stderr, "请输入管理员密码: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			fatal("❌ 未读取到密码", "error", err)
+		}
+		hash, err := components.HashPassword(password)
+		if err != nil {
+			fatal("❌ 生成密码哈希失败", "error", err)
+		}
+		fmt.Println(hash)
+		return
+	}
+
+	// 设置日志输出、格式和级别
 	var outputs []io.Writer
 	if GlobalConfig.Logging.ConsoleOutput {
 		outputs = append(outputs, os.Stdout)
 	}
+	// 日志文件（含单独配置的访问日志文件），收到 SIGUSR1 时重新打开
+	var logFiles []*utils.RotatingFile
 	if GlobalConfig.Logging.FileOutput {
-		logFile, err := os.OpenFile(GlobalConfig.Logging.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		logFile := openLogFile(GlobalConfig.Logging.LogFile)
+		defer logFile.Close()
+		logFiles = append(logFiles, logFile)
+		outputs = append(outputs, logFile)
+	}
+	logOutput := io.Discard
+	if len(outputs) > 0 {
+		logOutput = io.MultiWriter(outputs...)
+	}
+	if err := utils.SetupLogging(utils.LogOptions{
+		Level:           GlobalConfig.Logging.LogLevel,
+		Format:          GlobalConfig.Logging.Format,
+		ComponentLevels: GlobalConfig.Logging.ComponentLevels,
+		RedactParams:    GlobalConfig.Logging.RedactParams,
+	}, logOutput); err != nil {
+		fatal("❌ 日志配置无效", "error", err)
+	}
+
+	// 访问日志：未配置单独的文件时与程序日志输出到同一位置
+	var accessLogger *utils.AccessLogger
+	if a := GlobalConfig.AccessLog; a.Enabled {
+		accessOutput := logOutput
+		if a.File != "" {
+			accessFile := openLogFile(a.File)
+			defer accessFile.Close()
+			logFiles = append(logFiles, accessFile)
+			accessOutput = accessFile
+		}
+		var err error
+		accessLogger, err = utils.NewAccessLogger(utils.AccessLogOptions{
+			Format:      a.Format,
+			SampleRate:  a.SampleRate,
+			SampleRules: a.SampleRules,
+			Exclude:     a.Exclude,
+		}, accessOutput)
 		if err != nil {
-			log.Fatalf("无法打开日志文件: %v", err)
+			fatal("❌ 访问日志配置无效", "error", err)
 		}
-		outputs = append(outputs, logFile)
 	}
 
-	if len(outputs) > 0 {
-		log.SetOutput(io.MultiWriter(outputs...))
+	// 收到 SIGUSR1 时重新打开日志文件，兼容外部 logrotate
+	if len(logFiles) > 0 {
+		reopen := make(chan os.Signal, 1)
+		notifyLogReopen(reopen)
+		go func() {
+			for range reopen {
+				for _, f := range logFiles {
+					if err := f.Reopen(); err != nil {
+						mainLog.Error("❌ 重新打开日志文件失败", "error", err)
+					}
+				}
+				mainLog.Info("📝 已重新打开日志文件", "count", len(logFiles))
+			}
+		}()
+	}
+
+	// 打开监听：端口被占用时默认报错，-kill-port 或 port_fallback 可改变处理方式
+	listener, err := openListener(listenOptions{
+		Host:       GlobalConfig.Server.Host,
+		Port:       *port,
+		Fallback:   GlobalConfig.Server.PortFallback,
+		KillPort:   *killPort,
+		UnixSocket: *unixSocket,
+	})
+	if err != nil {
+		fatal("❌ 服务启动失败", "error", err)
+	}
+	if p := listenerPort(listener); p != "" {
+		*port = p
+	}
+
+	// 初始化代理带宽限速
+	bandwidthLimiter, err := components.NewBandwidthLimiter(GlobalConfig)
+	if err != nil {
+		fatal("❌ 初始化带宽限速失败", "error", err)
+	}
+	components.SetProxyBandwidthLimiter(bandwidthLimiter)
+	if GlobalConfig.Bandwidth.Enabled {
+		mainLog.Info("🚦 代理带宽限速已启用 (KB/s，0 表示不限制)",
+			"global_kbps", GlobalConfig.Bandwidth.GlobalLimitKBps, "client_kbps", GlobalConfig.Bandwidth.ClientLimitKBps)
+	}
+
+	// 初始化接口限流
+	rateLimiter := components.NewRateLimiter()
+	if err := rateLimiter.LoadFromConfigFile(configData); err != nil {
+		fatal("❌ 加载限流配置失败", "error", err)
+	}
+	if rateLimiter.Enabled() {
+		mainLog.Info("🚦 接口限流已启用")
+	}
+
+	// 初始化管理员认证
+	authManager, err := components.NewAuthManager(GlobalConfig)
+	if err != nil {
+		fatal("❌ 初始化管理员认证失败", "error", err)
+	}
+
+	// 服务端内容过滤
+	sourcesConfig.SetContentFilter(components.NewContentFilter(GlobalConfig, authManager))
+
+	// 搜索结果缓存
+	if GlobalConfig.Cache.Enabled {
+		searchCache, err := components.NewResponseCache("搜索", GlobalConfig.Cache.MaxEntries, GlobalConfig.Cache.Dir)
+		if err != nil {
+			fatal("❌ 初始化搜索缓存失败", "error", err)
+		}
+		c := GlobalConfig.Cache
+		sourcesConfig.SetSearchCache(searchCache,
+			components.SecondsPolicy(c.SearchTTLSeconds, c.StaleWhileRevalidateSeconds, c.StaleIfErrorSeconds),
+			components.SecondsPolicy(c.LatestTTLSeconds, c.StaleWhileRevalidateSeconds, c.StaleIfErrorSeconds))
+		matchCache, err := components.NewResponseCache("豆瓣匹配", c.MaxEntries, c.Dir)
+		if err != nil {
+			fatal("❌ 初始化匹配缓存失败", "error", err)
+		}
+		sourcesConfig.SetMatchCache(matchCache,
+			components.SecondsPolicy(c.MatchTTLSeconds, c.StaleWhileRevalidateSeconds, c.StaleIfErrorSeconds))
+		mainLog.Info("🗃️ 搜索结果缓存已启用", "search_ttl_seconds", c.SearchTTLSeconds, "latest_ttl_seconds", c.LatestTTLSeconds)
 	}
 
-	// 检查并处理端口占用
-	log.Printf("🔍 检查端口 %s 是否可用...", *port)
-	if err := checkAndKillPortProcess(*port); err != nil {
-		log.Fatalf("❌ 端口检查失败: %v", err)
+	// 豆瓣响应缓存与限流退避
+	if d := GlobalConfig.Douban; d.CacheEnabled {
+		doubanCache, err := components.NewResponseCache("豆瓣", GlobalConfig.Cache.MaxEntries, GlobalConfig.Cache.Dir)
+		if err != nil {
+			fatal("❌ 初始化豆瓣缓存失败", "error", err)
+		}
+		components.SetDoubanCache(doubanCache, map[string]components.CachePolicy{
+			"tags":     components.SecondsPolicy(d.TagsTTLSeconds, d.StaleWhileRevalidateSeconds, d.StaleIfErrorSeconds),
+			"subjects": components.SecondsPolicy(d.SubjectsTTLSeconds, d.StaleWhileRevalidateSeconds, d.StaleIfErrorSeconds),
+			"detail":   components.SecondsPolicy(d.DetailTTLSeconds, d.StaleWhileRevalidateSeconds, d.StaleIfErrorSeconds),
+			"suggest":  components.SecondsPolicy(d.SuggestTTLSeconds, d.StaleWhileRevalidateSeconds, d.StaleIfErrorSeconds),
+			"chart":    components.SecondsPolicy(d.ChartTTLSeconds, d.StaleWhileRevalidateSeconds, d.StaleIfErrorSeconds),
+		})
+	}
+	components.SetDoubanProxyCovers(GlobalConfig.Douban.ProxyCovers)
+
+	// 海报与封面图片代理
+	var imageProxy *components.ImageProxy
+	if GlobalConfig.Image.Enabled {
+		imageProxy, err = components.NewImageProxy(GlobalConfig)
+		if err != nil {
+			fatal("❌ 初始化图片代理失败", "error", err)
+		}
+		components.SetDoubanCoverEndpoint("/img")
+		mainLog.Info("🖼️ 图片代理已启用", "cache_dir", GlobalConfig.Image.CacheDir)
+	}
+	components.SetDoubanBackoff(time.Duration(GlobalConfig.Douban.BackoffSeconds)*time.Second,
+		time.Duration(GlobalConfig.Douban.MaxBackoffSeconds)*time.Second)
+
+	// 视频源熔断
+	sourcesConfig.SetBreakerGroup(components.NewBreakerGroup(GlobalConfig.Breaker.FailureThreshold,
+		time.Duration(GlobalConfig.Breaker.CooldownSeconds)*time.Second))
+
+	// 后台任务随服务关闭一起停止
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	bandwidthLimiter.Start(backgroundCtx)
+	authManager.StartCleanup(backgroundCtx)
+	if imageProxy != nil {
+		imageProxy.StartCachePruner(backgroundCtx)
+	}
+	if c := GlobalConfig.Cache; c.Dir != "" && (c.Enabled || GlobalConfig.Douban.CacheEnabled) {
+		components.StartCacheDirPruner(backgroundCtx, "响应", c.Dir,
+			responseCacheMaxAge(GlobalConfig), int64(c.DiskMaxMB)<<20)
+	}
+
+	// 加载 scorpio 候选资源并启动后台定时检测
+	scorpioPath := GlobalConfig.Scorpio.File
+	if scorpioPath == "" {
+		scorpioPath = components.ScorpioJsonPath
+	}
+	historyPath := GlobalConfig.Scorpio.HistoryFile
+	if historyPath == "" {
+		historyPath = components.ScorpioHistoryPath
+	}
+	scorpioHistory := components.NewScorpioHistory(historyPath, GlobalConfig.Scorpio.HistorySize)
+	if err := scorpioHistory.Load(); err != nil {
+		mainLog.Warn("⚠️ 读取 scorpio 检测历史失败", "file", historyPath, "error", err)
+	}
+	scorpioManager = components.NewScorpioManager(scorpioPath, scorpioHistory, components.ValidateOptions{
+		SampleKeyword: GlobalConfig.Scorpio.SampleKeyword,
+		ProbePlayURL:  GlobalConfig.Scorpio.ProbePlayURL,
+	})
+	scorpioPromoter := components.NewScorpioPromoter(sourcesConfig, scorpioManager)
+	if top := GlobalConfig.Scorpio.AutoPromoteTop; top > 0 {
+		scorpioManager.SetRoundHook(func() {
+			promoted, err := scorpioPromoter.AutoPromote(top, GlobalConfig.Scorpio.AutoPromoteReplace)
+			if err != nil {
+				mainLog.Warn("⚠️ 自动提升 scorpio 资源失败", "error", err)
+				return
+			}
+			mainLog.Info("⬆️ 自动提升 scorpio 资源完成", "promoted", len(promoted))
+		})
+	}
+	if err := scorpioManager.Load(); err != nil {
+		mainLog.Warn("⚠️ 读取 scorpio 资源失败", "file", scorpioPath, "error", err)
+	} else if GlobalConfig.Scorpio.CheckEnabled {
+		interval := time.Duration(GlobalConfig.Scorpio.CheckIntervalMinutes) * time.Minute
+		if interval <= 0 {
+			interval = 6 * time.Hour
+		}
+		scorpioManager.StartScheduler(backgroundCtx, interval, GlobalConfig.Scorpio.CheckConcurrency,
+			time.Duration(GlobalConfig.Scorpio.CheckJitterSeconds)*time.Second)
+		mainLog.Info("🩺 scorpio 资源后台检测已启用", "interval", interval)
 	}
 
 	// 注册路由
 	if GlobalConfig.Features.ProxyService {
-		http.HandleFunc("/proxy", func(w http.ResponseWriter, r *http.Request) {
+		http.HandleFunc("/proxy", rateLimiter.Wrap("/proxy", func(w http.ResponseWriter, r *http.Request) {
 			components.ProxyHandler(w, r, GlobalConfig)
-		})
+		}))
+	}
+	if imageProxy != nil {
+		http.HandleFunc("/img", rateLimiter.Wrap("/img", imageProxy.HandleImageAPI))
 	}
 	if GlobalConfig.Features.HealthCheck {
 		http.HandleFunc("/health", healthHandler)
+		http.HandleFunc("/ready", readyHandler)
 	}
 	if GlobalConfig.Features.InfoPage {
 		http.HandleFunc("/info", infoHandler)
@@ -268,71 +430,116 @@ func main() {
 		http.HandleFunc("/", indexHandler)
 	}
 	if GlobalConfig.Features.DoubanAPI {
-		http.HandleFunc("/douban", func(w http.ResponseWriter, r *http.Request) {
+		http.HandleFunc("/douban", rateLimiter.Wrap("/douban", func(w http.ResponseWriter, r *http.Request) {
 			components.DoubanHandler(w, r, GlobalConfig)
-		})
+		}))
 	}
 
 	// 添加视频源API路由
 	http.HandleFunc("/api/sources", sourcesConfig.HandleSourcesAPI)
-	http.HandleFunc("/api/source_search", sourcesConfig.HandleSourceSearchAPI)
+	http.HandleFunc("/api/source_status", sourcesConfig.HandleSourceStatusAPI)
+	http.HandleFunc("/api/douban_match", rateLimiter.Wrap("/api/douban_match", sourcesConfig.HandleDoubanMatchAPI))
+	http.HandleFunc("/api/sources/", authManager.RequireAdmin(sourcesConfig.HandleSourceAdminAPI))
+	http.HandleFunc("/api/source_search", rateLimiter.Wrap("/api/source_search", sourcesConfig.HandleSourceSearchAPI))
+
+	// 添加管理员认证API路由
+	http.HandleFunc("/api/auth/login", rateLimiter.Wrap("/api/auth/login", authManager.HandleLoginAPI))
+	http.HandleFunc("/api/auth/logout", authManager.HandleLogoutAPI)
+	http.HandleFunc("/api/auth/status", authManager.HandleAuthStatusAPI)
+
+	// 添加带宽统计API路由（需要管理员认证）
+	http.HandleFunc("/api/admin/bandwidth", authManager.RequireAdmin(bandwidthLimiter.HandleBandwidthStatsAPI))
 
 	// 添加过滤配置API路由
-	http.HandleFunc("/api/filter_config", filterConfigHandler)
+	http.HandleFunc("/api/filter_config", authManager.HandleFilterConfigAPI)
 
-	// 新增：资源检测页面和SSE流
+	// 新增：资源检测页面和SSE流（批量检测需要管理员认证）
 	http.HandleFunc("/check_sources", checkSourcesPageHandler)
-	// 移除 http.HandleFunc("/check_sources/stream", ...) 及 checkSourcesStreamHandler 相关实现
+	http.HandleFunc("/check_sources/stream", authManager.RequireAdmin(scorpioManager.HandleCheckStreamAPI))
 
 	// 添加 scorpio 源 API 路由
-	http.HandleFunc("/api/scorpio_sources", components.HandleScorpioSourcesAPI)
-	http.HandleFunc("/api/scorpio_sources/", components.HandleScorpioSourcesAPI)
-	http.HandleFunc("/api/check_source", HandleCheckSourceAPI)
+	http.HandleFunc("/api/scorpio_sources", scorpioManager.HandleScorpioSourcesAPI)
+	http.HandleFunc("/api/scorpio_sources/", scorpioManager.HandleScorpioSourcesAPI)
+	http.HandleFunc("/api/admin/scorpio/promote", authManager.RequireAdmin(scorpioPromoter.HandlePromoteAPI))
+	http.HandleFunc("/api/admin/scorpio/demote", authManager.RequireAdmin(scorpioPromoter.HandleDemoteAPI))
+	http.HandleFunc("/api/admin/scorpio/auto_promote", authManager.RequireAdmin(scorpioPromoter.HandleAutoPromoteAPI))
+	http.HandleFunc("/api/check_source", rateLimiter.Wrap("/api/check_source",
+		checkSourceHandler(authManager, func(api string) bool {
+			return sourcesConfig.HasEndpoint(api) || scorpioManager.HasAPI(api)
+		})))
 
 	// 获取本地IP地址
 	localIP := components.GetLocalIP()
 
-	log.Println("🚀 VastProxy-Go 代理服务启动中...")
-	log.Printf("📍 服务地址: http://%s:%s", localIP, *port)
+	// 配置 HTTPS
+	var handler http.Handler = http.DefaultServeMux
+	if accessLogger != nil {
+		handler = accessLogger.Middleware(handler)
+	}
+	srv := newHTTPServer(GlobalConfig, utils.WithRequestLogging(handler))
+	if err := setupTLS(backgroundCtx, GlobalConfig, srv); err != nil {
+		fatal("❌ 初始化 TLS 失败", "error", err)
+	}
+	scheme := "http"
+	if srv.TLSConfig != nil {
+		scheme = "https"
+		if GlobalConfig.TLS.HTTP2 {
+			mainLog.Info("🔐 HTTPS 已启用 (HTTP/2)")
+		} else {
+			mainLog.Info("🔐 HTTPS 已启用")
+		}
+	}
+
+	mainLog.Info("🚀 VastProxy-Go 代理服务启动中...")
+	baseURL := scheme + "://" + net.JoinHostPort(GlobalConfig.Server.Host, *port)
+	if listenerPort(listener) == "" {
+		mainLog.Info("📍 服务地址", "network", listener.Addr().Network(), "addr", listener.Addr().String())
+	} else {
+		mainLog.Info("📍 服务地址", "url", scheme+"://"+net.JoinHostPort(localIP, *port))
+	}
 	if GlobalConfig.Features.HealthCheck {
-		log.Printf("🔗 健康检查: http://%s:%s/health", GlobalConfig.Server.Host, *port)
+		mainLog.Info("🔗 健康检查", "url", baseURL+"/health")
 	}
 	if GlobalConfig.Features.InfoPage {
-		log.Printf("📄 信息页面: http://%s:%s/info", GlobalConfig.Server.Host, *port)
-		log.Printf("📱 移动端页面: http://%s:%s/mobile", GlobalConfig.Server.Host, *port)
-		log.Printf("🏠 首页(移动端): http://%s:%s/", GlobalConfig.Server.Host, *port)
+		mainLog.Info("📄 信息页面", "url", baseURL+"/info")
+		mainLog.Info("📱 移动端页面", "url", baseURL+"/mobile")
+		mainLog.Info("🏠 首页(移动端)", "url", baseURL+"/")
 	}
 	if GlobalConfig.Features.DoubanAPI {
-		log.Printf("🎬 豆瓣API: http://%s:%s/douban", GlobalConfig.Server.Host, *port)
+		mainLog.Info("🎬 豆瓣API", "url", baseURL+"/douban")
+	}
+	mainLog.Info("🎯 视频源API", "url", baseURL+"/api/sources")
+	if GlobalConfig.Logging.FileOutput {
+		mainLog.Info("📝 日志文件", "file", GlobalConfig.Logging.LogFile)
 	}
-	log.Printf("🎯 视频源API: http://%s:%s/api/sources", GlobalConfig.Server.Host, *port)
-	log.Printf("📝 日志文件: %s", GlobalConfig.Logging.LogFile)
-	log.Println(strings.Repeat("=", 50))
-
-	// 启动服务器
-	go func() {
-		err := http.ListenAndServe(GlobalConfig.Server.Host+":"+*port, nil)
-		if err != nil {
-			log.Fatalf("服务启动失败: %v", err)
-		}
-	}()
-
-	// 等待一秒确保服务器启动
-	time.Sleep(1 * time.Second)
 
-	log.Printf("📱 服务器已启动，访问地址: http://%s:%s/", localIP, *port)
+	// 启动服务器：监听成功即视为就绪，不再等待固定时长
+	mainLog.Info("📱 服务器已启动", "url", scheme+"://"+net.JoinHostPort(localIP, *port)+"/")
 
-	// 设置信号处理
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// HTTP 跳转到 HTTPS
+	var redirectSrv *http.Server
+	if srv.TLSConfig != nil && GlobalConfig.TLS.RedirectPort != "" {
+		redirectSrv = newRedirectServer(GlobalConfig, *port)
+		go func() {
+			if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				mainLog.Error("⚠️ HTTP 跳转服务启动失败", "error", err)
+			}
+		}()
+		mainLog.Info("↪️ HTTP 跳转服务已启动，请求将跳转到 HTTPS", "addr", redirectSrv.Addr)
+	}
 
-	// 保持主程序运行，等待信号或浏览器关闭
-	select {
-	case sig := <-sigChan:
-		log.Printf("📴 收到信号 %v，正在退出...", sig)
-	case <-time.After(24 * time.Hour): // 防止无限等待
-		log.Println("⏰ 程序运行超时，正在退出...")
+	drain := secondsOr(GlobalConfig.Server.ShutdownTimeoutSeconds, 30)
+	err = runServer(srv, listener, drain, func() {
+		stopBackground()
+		if redirectSrv != nil {
+			redirectSrv.Close()
+		}
+	})
+	if err != nil {
+		mainLog.Error("❌ 服务异常退出", "error", err)
+		os.Exit(1)
 	}
+	mainLog.Info("👋 服务已关闭")
 }
 
 // LoadConfig 加载配置文件
@@ -371,14 +578,14 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 	// 读取嵌入的 HTML 文件
 	content, err := htmlContent.ReadFile("html/info.html")
 	if err != nil {
-		log.Printf("❌ 读取嵌入的 HTML 文件失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		mainLog.ErrorContext(r.Context(), "❌ 读取嵌入的 HTML 文件失败", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	// 返回 HTML 内容
 	w.Write(content)
-	log.Printf("📄 返回信息页面 HTML [IP:%s]", utils.GetRequestIP(r))
+	mainLog.DebugContext(r.Context(), "📄 返回信息页面 HTML")
 }
 
 func mobileHandler(w http.ResponseWriter, r *http.Request) {
@@ -391,13 +598,13 @@ func mobileHandler(w http.ResponseWriter, r *http.Request) {
 
 	content, err := htmlContent.ReadFile("html/index_mobile.html")
 	if err != nil {
-		log.Printf("❌ 读取 html/index_mobile.html 失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		mainLog.ErrorContext(r.Context(), "❌ 读取 html/index_mobile.html 失败", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(content)
-	log.Printf("📱 返回移动端页面 html/index_mobile.html [IP:%s]", utils.GetRequestIP(r))
+	mainLog.DebugContext(r.Context(), "📱 返回移动端页面 html/index_mobile.html")
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
@@ -411,12 +618,12 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	// 直接返回移动端页面，取消客户端类型判断
 	content, err := htmlContent.ReadFile("html/index_mobile.html")
 	if err != nil {
-		log.Printf("❌ 读取 html/index_mobile.html 失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		mainLog.ErrorContext(r.Context(), "❌ 读取 html/index_mobile.html 失败", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 	w.Write(content)
-	log.Printf("📱 返回移动端页面 html/index_mobile.html [IP:%s]", utils.GetRequestIP(r))
+	mainLog.DebugContext(r.Context(), "📱 返回移动端页面 html/index_mobile.html")
 }
 
 func aboutHandler(w http.ResponseWriter, r *http.Request) {
@@ -427,145 +634,26 @@ func aboutHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	content, err := htmlContent.ReadFile("html/about.html")
 	if err != nil {
-		log.Printf("❌ 读取 html/about.html 失败: %v [IP:%s]", err, utils.GetRequestIP(r))
+		mainLog.ErrorContext(r.Context(), "❌ 读取 html/about.html 失败", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 	w.Write(content)
-	log.Printf("📄 返回关于页面 html/about.html [IP:%s]", utils.GetRequestIP(r))
+	mainLog.DebugContext(r.Context(), "📄 返回关于页面 html/about.html")
 }
 
-func filterConfigHandler(w http.ResponseWriter, r *http.Request) {
-	// 设置CORS头
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	// 处理OPTIONS请求
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	// 只允许GET请求
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// 返回过滤配置
-	response := map[string]interface{}{
-		"success": true,
-		"data": map[string]interface{}{
-			"admin_password":       GlobalConfig.Filter.AdminPassword,
-			"default_adult_filter": GlobalConfig.Filter.DefaultAdultFilter,
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-	log.Printf("✅ /api/filter_config 请求 [IP:%s]", utils.GetRequestIP(r))
-}
-
-// checkAndKillPortProcess 检查端口是否被占用，如果被占用则杀死相关进程
-func checkAndKillPortProcess(port string) error {
-	// 尝试监听端口来检查是否被占用
-	listener, err := net.Listen("tcp", ":"+port)
+// openLogFile 按 [logging] 的轮转配置打开日志文件，失败时退出
+func openLogFile(path string) *utils.RotatingFile {
+	l := GlobalConfig.Logging
+	f, err := utils.NewRotatingFile(utils.RotateOptions{
+		Path:       path,
+		MaxSizeMB:  l.MaxSizeMB,
+		Interval:   time.Duration(l.RotateIntervalHours) * time.Hour,
+		MaxBackups: l.MaxBackups,
+		Compress:   l.Compress,
+	})
 	if err != nil {
-		log.Printf("⚠️  端口 %s 被占用，正在查找并杀死相关进程...", port)
-
-		// 根据操作系统查找并杀死占用端口的进程
-		switch runtime.GOOS {
-		case "darwin", "linux":
-			return killProcessOnPortUnix(port)
-		case "windows":
-			return killProcessOnPortWindows(port)
-		default:
-			return fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
-		}
+		fatal("❌ 无法打开日志文件", "file", path, "error", err)
 	}
-
-	// 端口可用，关闭监听器
-	listener.Close()
-	log.Printf("✅ 端口 %s 可用", port)
-	return nil
-}
-
-// killProcessOnPortUnix 在Unix系统上杀死占用指定端口的进程
-func killProcessOnPortUnix(port string) error {
-	cmd := exec.Command("lsof", "-ti", ":"+port)
-	output, err := cmd.Output()
-	if err != nil {
-		log.Printf("⚠️  未找到占用端口 %s 的进程，可能端口被系统保留", port)
-		return nil
-	}
-	pids := strings.Fields(string(output))
-	if len(pids) == 0 {
-		log.Printf("✅ 端口 %s 已释放", port)
-		return nil
-	}
-	for _, pid := range pids {
-		pid = strings.TrimSpace(pid)
-		if pid == "" {
-			continue
-		}
-		log.Printf("🔫 正在杀死进程 PID: %s", pid)
-		killCmd := exec.Command("kill", "-9", pid)
-		if err := killCmd.Run(); err != nil {
-			log.Printf("⚠️  杀死进程 %s 失败: %v", pid, err)
-		} else {
-			log.Printf("✅ 成功杀死进程 PID: %s", pid)
-		}
-	}
-	time.Sleep(500 * time.Millisecond)
-	listener, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return fmt.Errorf("端口 %s 仍然被占用，无法启动服务", port)
-	}
-	listener.Close()
-	log.Printf("✅ 端口 %s 已成功释放并可用", port)
-	return nil
-}
-
-// killProcessOnPortWindows 在Windows系统上杀死占用指定端口的进程
-func killProcessOnPortWindows(port string) error {
-	cmd := exec.Command("netstat", "-ano")
-	output, err := cmd.Output()
-	if err != nil {
-		return fmt.Errorf("执行 netstat 失败: %v", err)
-	}
-	lines := strings.Split(string(output), "\n")
-	var pids []string
-	for _, line := range lines {
-		if strings.Contains(line, ":"+port) && strings.Contains(line, "LISTENING") {
-			fields := strings.Fields(line)
-			if len(fields) >= 5 {
-				pid := fields[len(fields)-1]
-				if pid != "0" {
-					pids = append(pids, pid)
-				}
-			}
-		}
-	}
-	if len(pids) == 0 {
-		log.Printf("✅ 端口 %s 已释放", port)
-		return nil
-	}
-	for _, pid := range pids {
-		log.Printf("🔫 正在杀死进程 PID: %s", pid)
-		killCmd := exec.Command("taskkill", "/F", "/PID", pid)
-		if err := killCmd.Run(); err != nil {
-			log.Printf("⚠️  杀死进程 %s 失败: %v", pid, err)
-		} else {
-			log.Printf("✅ 成功杀死进程 PID: %s", pid)
-		}
-	}
-	time.Sleep(500 * time.Millisecond)
-	listener, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return fmt.Errorf("端口 %s 仍然被占用，无法启动服务", port)
-	}
-	listener.Close()
-	log.Printf("✅ 端口 %s 已成功释放并可用", port)
-	return nil
+	return f
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vastproxy-go/components"
+	"vastproxy-go/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckSourceHandler(t *testing.T) {
+	var requests int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"code":1,"pagecount":1,"list":[]}`))
+	}))
+	defer upstream.Close()
+	known := upstream.URL + "/known/"
+	unknown := upstream.URL + "/unknown/"
+
+	config := &utils.Config{}
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Auth.PasswordHash = string(hash)
+	am, err := components.NewAuthManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	am.HandleLoginAPI(w, httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"password":"secret"}`)))
+	var login struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil || login.Data.Token == "" {
+		t.Fatalf("登录失败: %s", w.Body.String())
+	}
+
+	handler := checkSourceHandler(am, func(api string) bool { return api == known })
+	tests := []struct {
+		name         string
+		api          string
+		probe        bool
+		admin        bool
+		wantStatus   int
+		wantUpstream bool
+	}{
+		{"匿名检测已配置的资源", known, false, false, http.StatusOK, true},
+		{"匿名检测任意地址", unknown, false, false, http.StatusUnauthorized, false},
+		{"匿名探测播放地址", known, true, false, http.StatusUnauthorized, false},
+		{"匿名请求缺少地址", "", false, false, http.StatusUnauthorized, false},
+		{"管理员检测任意地址", unknown, false, true, http.StatusOK, true},
+		{"管理员探测播放地址", known, true, true, http.StatusOK, true},
+		{"管理员请求缺少地址", "", false, true, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&requests, 0)
+		query := url.Values{"api": {tt.api}}
+		if tt.probe {
+			query.Set("probe", "1")
+		}
+		r := httptest.NewRequest("GET", "/api/check_source?"+query.Encode(), nil)
+		if tt.admin {
+			r.Header.Set("Authorization", "Bearer "+login.Data.Token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if got := atomic.LoadInt32(&requests) > 0; got != tt.wantUpstream {
+			t.Errorf("%s: 是否请求了上游 = %v, want %v", tt.name, got, tt.wantUpstream)
+		}
+	}
+}
+
+func TestResponseCacheMaxAge(t *testing.T) {
+	newConfig := func(diskHours int, cache, douban bool) *utils.Config {
+		config := &utils.Config{}
+		config.Cache.Enabled = cache
+		config.Cache.DiskMaxAgeHours = diskHours
+		config.Cache.SearchTTLSeconds = 1800
+		config.Cache.StaleIfErrorSeconds = 86400
+		config.Douban.CacheEnabled = douban
+		config.Douban.DetailTTLSeconds = 86400
+		config.Douban.StaleIfErrorSeconds = 604800
+		return config
+	}
+	tests := []struct {
+		name   string
+		config *utils.Config
+		want   time.Duration
+	}{
+		{"配置值足够长", newConfig(240, true, true), 240 * time.Hour},
+		{"按豆瓣缓存延长", newConfig(48, true, true), 8 * 24 * time.Hour},
+		{"只启用搜索缓存", newConfig(12, true, false), 24*time.Hour + 30*time.Minute},
+		{"0 表示不按时长清理", newConfig(0, true, true), 0},
+	}
+	for _, tt := range tests {
+		if got := responseCacheMaxAge(tt.config); got != tt.want {
+			t.Errorf("%s: responseCacheMaxAge() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
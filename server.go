@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// serverLog 服务监听与生命周期日志
+var serverLog = utils.Logger("server")
+
+// serverReady 服务是否可以接收新请求：监听成功后置为 true，开始关闭时置为 false
+var serverReady atomic.Bool
+
+// newHTTPServer 根据 [server] 配置创建 http.Server
+// 写超时默认不限制，避免中断代理的长时间视频流
+func newHTTPServer(config *utils.Config, handler http.Handler) *http.Server {
+	s := config.Server
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: secondsOr(s.ReadHeaderTimeoutSeconds, 10),
+		ReadTimeout:       secondsOr(s.ReadTimeoutSeconds, 30),
+		WriteTimeout:      time.Duration(s.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       secondsOr(s.IdleTimeoutSeconds, 120),
+	}
+}
+
+// secondsOr 将秒数转换为时长，未配置时使用默认值
+func secondsOr(seconds, def int) time.Duration {
+	if seconds <= 0 {
+		seconds = def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// runServer 在已监听的 listener 上提供服务（设置了 TLSConfig 时为 HTTPS），收到 SIGINT/SIGTERM 后优雅关闭
+// 关闭时先停止接收新连接并标记为未就绪，等待进行中的请求（如代理视频流）在 drain 时长内结束，
+// 超时或再次收到信号时强制关闭剩余连接。服务异常退出时返回错误
+func runServer(srv *http.Server, ln net.Listener, drain time.Duration, onShutdown func()) error {
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	return serveUntilSignal(srv, ln, drain, sigChan, onShutdown)
+}
+
+// serveUntilSignal 提供服务直到 sigChan 收到第一个信号，之后按 runServer 的流程优雅关闭
+func serveUntilSignal(srv *http.Server, ln net.Listener, drain time.Duration, sigChan <-chan os.Signal, onShutdown func()) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+	serverReady.Store(true)
+
+	select {
+	case err := <-serveErr:
+		serverReady.Store(false)
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case sig := <-sigChan:
+		serverLog.Info("📴 收到退出信号，停止接收新请求并等待进行中的请求结束", "signal", sig.String(), "drain", drain)
+	}
+
+	serverReady.Store(false)
+	if onShutdown != nil {
+		onShutdown()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-sigChan:
+			serverLog.Warn("📴 再次收到退出信号，立即关闭", "signal", sig.String())
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		serverLog.Warn("⚠️ 等待超时，强制关闭剩余连接", "error", err)
+		srv.Close()
+	} else {
+		serverLog.Info("✅ 所有请求已结束", "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// readyHandler 就绪检查：服务监听成功且未在关闭中时返回 200，否则返回 503
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if !serverReady.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"unavailable"}`))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ready"}`))
+}
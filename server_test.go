@@ -0,0 +1,200 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testServer 启动 serveUntilSignal，/slow 请求阻塞到 release 关闭为止
+type testServer struct {
+	addr     string
+	sigs     chan os.Signal
+	entered  chan struct{}
+	release  chan struct{}
+	shutdown chan struct{}
+	done     chan error
+}
+
+func startTestServer(t *testing.T, drain time.Duration) *testServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testServer{
+		addr:     ln.Addr().String(),
+		sigs:     make(chan os.Signal, 2),
+		entered:  make(chan struct{}, 1),
+		release:  make(chan struct{}),
+		shutdown: make(chan struct{}),
+		done:     make(chan error, 1),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		ts.entered <- struct{}{}
+		select {
+		case <-ts.release:
+			io.WriteString(w, "done")
+		case <-r.Context().Done():
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go func() {
+		ts.done <- serveUntilSignal(srv, ln, drain, ts.sigs, func() { close(ts.shutdown) })
+	}()
+	t.Cleanup(func() {
+		select {
+		case <-ts.release:
+		default:
+			close(ts.release)
+		}
+	})
+	return ts
+}
+
+// slowRequest 发起 /slow 请求，等待处理函数开始执行后返回结果通道
+func (ts *testServer) slowRequest(t *testing.T) <-chan error {
+	t.Helper()
+	result := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + ts.addr + "/slow")
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		result <- err
+	}()
+	select {
+	case <-ts.entered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("请求未到达服务端")
+	}
+	return result
+}
+
+func TestServeUntilSignalDrain(t *testing.T) {
+	ts := startTestServer(t, 10*time.Second)
+	if resp, err := http.Get("http://" + ts.addr + "/ready"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("就绪检查 = %v, %v", resp, err)
+	}
+	inflight := ts.slowRequest(t)
+
+	ts.sigs <- syscall.SIGTERM
+	select {
+	case <-ts.shutdown:
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到信号后应调用 onShutdown")
+	}
+	if serverReady.Load() {
+		t.Error("关闭期间应标记为未就绪")
+	}
+
+	// 停止接收新连接，但等待进行中的请求结束
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", ts.addr, 100*time.Millisecond)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("关闭期间仍在接收新连接")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-ts.done:
+		t.Fatalf("进行中的请求结束前返回: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(ts.release)
+	if err := <-inflight; err != nil {
+		t.Errorf("进行中的请求应正常完成: %v", err)
+	}
+	select {
+	case err := <-ts.done:
+		if err != nil {
+			t.Errorf("serveUntilSignal() = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("请求结束后应返回")
+	}
+}
+
+func TestServeUntilSignalForceClose(t *testing.T) {
+	tests := []struct {
+		name         string
+		drain        time.Duration
+		secondSignal bool
+	}{
+		{"再次收到信号时立即关闭", 10 * time.Second, true},
+		{"等待超时后强制关闭", 100 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := startTestServer(t, tt.drain)
+			inflight := ts.slowRequest(t)
+
+			start := time.Now()
+			ts.sigs <- syscall.SIGINT
+			<-ts.shutdown
+			if tt.secondSignal {
+				ts.sigs <- syscall.SIGINT
+			}
+			select {
+			case err := <-ts.done:
+				if err != nil {
+					t.Errorf("serveUntilSignal() = %v, want nil", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("未能强制关闭")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("关闭耗时 %v", elapsed)
+			}
+			if err := <-inflight; err == nil {
+				t.Error("强制关闭后进行中的请求应失败")
+			}
+		})
+	}
+}
+
+func TestServeUntilSignalServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	called := false
+	err = serveUntilSignal(&http.Server{}, ln, time.Second, make(chan os.Signal), func() { called = true })
+	if err == nil || called {
+		t.Errorf("serveUntilSignal() = %v, onShutdown called = %v", err, called)
+	}
+	if serverReady.Load() {
+		t.Error("服务异常退出后应标记为未就绪")
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	for _, ready := range []bool{true, false} {
+		serverReady.Store(ready)
+		w := httptest.NewRecorder()
+		readyHandler(w, httptest.NewRequest("GET", "/ready", nil))
+		want := http.StatusServiceUnavailable
+		if ready {
+			want = http.StatusOK
+		}
+		if w.Code != want {
+			t.Errorf("ready = %v: status = %d, want %d", ready, w.Code, want)
+		}
+	}
+	serverReady.Store(false)
+}
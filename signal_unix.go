@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyLogReopen 收到 SIGUSR1 时通知重新打开日志文件（配合外部 logrotate 使用）
+func notifyLogReopen(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
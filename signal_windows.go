@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyLogReopen Windows 没有 SIGUSR1，日志文件只按配置自动轮转
+func notifyLogReopen(c chan<- os.Signal) {}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// 自签名证书的有效期，到期前 30 天重新生成
+const (
+	selfSignedValidity = 365 * 24 * time.Hour
+	selfSignedRenew    = 30 * 24 * time.Hour
+)
+
+// certReloader 证书热加载：定时检查证书和私钥文件的修改时间，变化后重新加载
+type certReloader struct {
+	certFile string
+	keyFile  string
+	renew    func() error // 不为 nil 时每次检查前调用，用于自签名证书到期前重新生成
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertReloader 加载证书，失败时返回错误
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload 重新读取证书和私钥
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %v", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = r.latestModTime()
+	r.mu.Unlock()
+	return nil
+}
+
+// latestModTime 返回证书和私钥文件中较新的修改时间
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// watch 按 interval 检查文件是否变化，ctx 取消时退出
+// 证书续期工具通常先后写入两个文件，加载失败时保留旧证书并在下次检查时重试
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if r.renew != nil {
+			if err := r.renew(); err != nil {
+				serverLog.Warn("⚠️ 重新生成自签名证书失败", "error", err)
+			}
+		}
+		r.mu.RLock()
+		changed := r.latestModTime().After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.reload(); err != nil {
+			serverLog.Warn("⚠️ 证书文件已变化，但重新加载失败", "error", err)
+			continue
+		}
+		serverLog.Info("🔐 证书已重新加载", "cert_file", r.certFile)
+	}
+}
+
+// GetCertificate 供 tls.Config 使用
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// setupTLS 根据 [tls] 配置为 srv 设置证书，未启用时不做修改
+// 启用 self_signed 且未配置 cert_file/key_file 时，在 self_signed_dir 中生成自签名证书，不存在或即将过期时重新生成；
+// 配置了证书文件时始终使用该证书，不会生成或覆盖
+func setupTLS(ctx context.Context, config *utils.Config, srv *http.Server) error {
+	c := config.TLS
+	if !c.Enabled {
+		return nil
+	}
+	certFile, keyFile := c.CertFile, c.KeyFile
+	var renew func() error
+	if c.SelfSigned && (certFile != "" || keyFile != "") {
+		serverLog.Warn("⚠️ 已配置 cert_file/key_file，忽略 self_signed，不会生成或覆盖证书", "cert_file", certFile, "key_file", keyFile)
+	} else if c.SelfSigned {
+		dir := c.SelfSignedDir
+		if dir == "" {
+			dir = "config/tls"
+		}
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile = filepath.Join(dir, "key.pem")
+		renew = func() error {
+			if !needsSelfSigned(certFile, keyFile) {
+				return nil
+			}
+			hosts := selfSignedHosts(config.Server.Host)
+			if err := generateSelfSigned(certFile, keyFile, hosts); err != nil {
+				return err
+			}
+			serverLog.Info("🔐 已生成自签名证书", "cert_file", certFile, "hosts", strings.Join(hosts, ", "))
+			return nil
+		}
+		if err := renew(); err != nil {
+			return err
+		}
+	}
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("[tls] 已启用但未配置 cert_file/key_file，也未开启 self_signed")
+	}
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	// 自签名证书在运行中到期前同样会重新生成，并由 watch 检测到文件变化后加载
+	reloader.renew = renew
+	interval := secondsOr(c.ReloadIntervalSeconds, 60)
+	go reloader.watch(ctx, interval)
+
+	srv.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if !c.HTTP2 {
+		// 非 nil 的空 TLSNextProto 会关闭 net/http 内置的 HTTP/2 支持
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	return nil
+}
+
+// needsSelfSigned 判断是否需要生成自签名证书：文件不存在、无法解析、即将过期或为 CA 证书
+func needsSelfSigned(certFile, keyFile string) bool {
+	if _, err := os.Stat(keyFile); err != nil {
+		return true
+	}
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return true
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+	// 旧版本生成的是 CA 证书，重新生成为叶子证书
+	return cert.IsCA || time.Until(cert.NotAfter) < selfSignedRenew
+}
+
+// selfSignedHosts 自签名证书包含的主机名与 IP：localhost、本机名、监听地址和局域网 IP
+func selfSignedHosts(listenHost string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	if listenHost != "" && listenHost != "0.0.0.0" && listenHost != "::" {
+		hosts = append(hosts, listenHost)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	unique := hosts[:0]
+	for _, h := range hosts {
+		if !seen[h] {
+			seen[h] = true
+			unique = append(unique, h)
+		}
+	}
+	return unique
+}
+
+// generateSelfSigned 生成 ECDSA P-256 自签名证书并写入 PEM 文件
+func generateSelfSigned(certFile, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("生成私钥失败: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("生成证书序列号失败: %v", err)
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"VastVideo-Go"}, CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedValidity),
+		// 只生成服务器叶子证书而非 CA：用户信任它后，泄露的私钥也无法用于签发其他域名的证书
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("生成证书失败: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("编码私钥失败: %v", err)
+	}
+
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("创建证书目录失败: %v", err)
+		}
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("写入私钥失败: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("写入证书失败: %v", err)
+	}
+	return nil
+}
+
+// newRedirectServer 创建将 HTTP 请求跳转到 HTTPS 的服务，httpsPort 为 HTTPS 监听端口
+func newRedirectServer(config *utils.Config, httpsPort string) *http.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.Trim(r.Host, "[]")
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		target := "https://" + net.JoinHostPort(host, httpsPort)
+		if httpsPort == "443" {
+			target = strings.TrimSuffix(target, ":443")
+		}
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	return &http.Server{
+		Addr:              net.JoinHostPort(config.Server.Host, config.TLS.RedirectPort),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vastproxy-go/utils"
+)
+
+// writeTestCert 写入指定有效期的证书和私钥，模拟运维配置的证书
+func writeTestCert(t *testing.T, certFile, keyFile string, notAfter time.Time, isCA bool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "operator.example.com"},
+		DNSNames:              []string{"operator.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testTLSConfig 返回启用 TLS 的配置
+func testTLSConfig(certFile, keyFile, selfSignedDir string, selfSigned bool) *utils.Config {
+	config := &utils.Config{}
+	config.TLS.Enabled = true
+	config.TLS.CertFile = certFile
+	config.TLS.KeyFile = keyFile
+	config.TLS.SelfSigned = selfSigned
+	config.TLS.SelfSignedDir = selfSignedDir
+	config.TLS.HTTP2 = true
+	return config
+}
+
+func TestSetupTLSKeepsOperatorCert(t *testing.T) {
+	tests := []struct {
+		name     string
+		notAfter time.Duration
+		isCA     bool
+	}{
+		{"即将过期", 24 * time.Hour, false},
+		{"CA 证书", 365 * 24 * time.Hour, true},
+		{"有效证书", 365 * 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+			writeTestCert(t, certFile, keyFile, time.Now().Add(tt.notAfter), tt.isCA)
+			before, _ := os.ReadFile(certFile)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			srv := &http.Server{}
+			selfSignedDir := filepath.Join(dir, "self")
+			if err := setupTLS(ctx, testTLSConfig(certFile, keyFile, selfSignedDir, true), srv); err != nil {
+				t.Fatal(err)
+			}
+
+			after, _ := os.ReadFile(certFile)
+			if !bytes.Equal(before, after) {
+				t.Error("self_signed 不应覆盖已配置的证书")
+			}
+			if _, err := os.Stat(selfSignedDir); !os.IsNotExist(err) {
+				t.Errorf("配置了证书时不应生成自签名证书: %v", err)
+			}
+			cert, err := srv.TLSConfig.GetCertificate(nil)
+			if err != nil || len(cert.Certificate) == 0 {
+				t.Fatalf("GetCertificate() = %v, %v", cert, err)
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil || leaf.Subject.CommonName != "operator.example.com" {
+				t.Errorf("使用的证书 = %v, %v", leaf.Subject, err)
+			}
+		})
+	}
+}
+
+func TestSetupTLSSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := &http.Server{}
+	if err := setupTLS(ctx, testTLSConfig("", "", dir, true), srv); err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	if needsSelfSigned(certFile, filepath.Join(dir, "key.pem")) {
+		t.Error("应在 self_signed_dir 中生成有效的自签名证书")
+	}
+	if srv.TLSConfig == nil || srv.TLSConfig.GetCertificate == nil {
+		t.Fatal("未设置 TLSConfig")
+	}
+
+	// 已有的自签名证书未过期时保留
+	before, _ := os.ReadFile(certFile)
+	if err := setupTLS(ctx, testTLSConfig("", "", dir, true), &http.Server{}); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(certFile); !bytes.Equal(before, after) {
+		t.Error("有效的自签名证书不应重新生成")
+	}
+}
+
+func TestSetupTLSMissingCert(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+	}{
+		{"未配置证书", "", ""},
+		{"只配置了证书", filepath.Join(dir, "cert.pem"), ""},
+		{"证书文件不存在", filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing.key")},
+	}
+	for _, tt := range tests {
+		if err := setupTLS(context.Background(), testTLSConfig(tt.certFile, tt.keyFile, dir, false), &http.Server{}); err == nil {
+			t.Errorf("%s: 应返回错误", tt.name)
+		}
+	}
+	// 只配置了其中一个文件时，self_signed 也不会补全另一个
+	if err := setupTLS(context.Background(), testTLSConfig(filepath.Join(dir, "cert.pem"), "", dir, true), &http.Server{}); err == nil {
+		t.Error("只配置了证书时应返回错误")
+	}
+}
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 访问日志格式
+const (
+	AccessLogCommon   = "common"   // Common Log Format
+	AccessLogCombined = "combined" // Combined Log Format，末尾附加耗时与上游信息
+	AccessLogJSON     = "json"     // 每行一个 JSON 对象
+)
+
+// AccessLogOptions 访问日志配置，对应 [access_log]
+type AccessLogOptions struct {
+	Format      string  // common、combined 或 json
+	SampleRate  float64 // 默认采样率（0~1）
+	SampleRules string  // 按路径设置采样率，如 "/proxy=0.1, *.m3u8=1"，先匹配的规则生效
+	Exclude     string  // 不记录的路径，如 "*.ts, /health, /ready"
+}
+
+// sampleRule 按路径的采样规则
+type sampleRule struct {
+	pattern string
+	rate    float64
+}
+
+// AccessLogger 访问日志中间件：记录每个请求的方法、路径、状态码、字节数、耗时、客户端 IP、User-Agent，
+// 以及 /proxy 等接口通过 SetUpstream 登记的上游主机与状态码
+type AccessLogger struct {
+	format      string
+	sampleRate  float64
+	sampleRules []sampleRule
+	exclude     []string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewAccessLogger 创建访问日志中间件，日志写入 w
+func NewAccessLogger(opts AccessLogOptions, w io.Writer) (*AccessLogger, error) {
+	a := &AccessLogger{
+		format:     strings.ToLower(strings.TrimSpace(opts.Format)),
+		sampleRate: opts.SampleRate,
+		w:          w,
+	}
+	switch a.format {
+	case "":
+		a.format = AccessLogCombined
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	default:
+		return nil, fmt.Errorf("无效的访问日志格式: %s，可选 common、combined 或 json", opts.Format)
+	}
+	if a.sampleRate < 0 || a.sampleRate > 1 {
+		return nil, fmt.Errorf("无效的访问日志采样率: %v，应在 0~1 之间", a.sampleRate)
+	}
+	for _, rule := range splitList(opts.SampleRules) {
+		pattern, value, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的访问日志采样规则: %s", rule)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("无效的访问日志采样规则: %s，采样率应在 0~1 之间", rule)
+		}
+		a.sampleRules = append(a.sampleRules, sampleRule{pattern: strings.TrimSpace(pattern), rate: rate})
+	}
+	a.exclude = splitList(opts.Exclude)
+	return a, nil
+}
+
+// splitList 按逗号拆分并去掉空白项
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// matchPath 判断路径是否匹配规则："*.ts" 按扩展名匹配，含 * 的按 path.Match 匹配，其他按路径前缀匹配
+func matchPath(pattern, p string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(p, pattern[1:])
+	}
+	if strings.Contains(pattern, "*") {
+		ok, _ := path.Match(pattern, p)
+		return ok
+	}
+	return p == pattern || strings.HasPrefix(p, strings.TrimSuffix(pattern, "/")+"/")
+}
+
+// upstreamKey 请求上下文中上游信息的键
+type upstreamKey struct{}
+
+// upstreamInfo 代理类接口访问的上游，由处理函数登记、访问日志读取
+type upstreamInfo struct {
+	host   string
+	path   string
+	status int
+}
+
+// SetUpstream 登记本次请求访问的上游地址和状态码（请求失败时 status 为 0），用于访问日志
+func SetUpstream(ctx context.Context, target *url.URL, status int) {
+	if info, ok := ctx.Value(upstreamKey{}).(*upstreamInfo); ok && target != nil {
+		info.host = target.Host
+		info.path = target.Path
+		info.status = status
+	}
+}
+
+// Middleware 包装处理器，请求结束后按排除规则和采样率记录访问日志
+// 5xx 响应不受采样率影响，总是记录
+func (a *AccessLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		upstream := &upstreamInfo{}
+		rec := &accessRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), upstreamKey{}, upstream)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		paths := []string{r.URL.Path}
+		if upstream.path != "" {
+			// /proxy?url=.../seg.ts 这类请求同时按上游路径匹配
+			paths = append(paths, upstream.path)
+		}
+		if a.excluded(paths) || (rec.status < 500 && !a.sampled(paths)) {
+			return
+		}
+		a.write(r, rec, upstream, start)
+	})
+}
+
+// excluded 判断请求是否在排除列表中
+func (a *AccessLogger) excluded(paths []string) bool {
+	for _, pattern := range a.exclude {
+		for _, p := range paths {
+			if matchPath(pattern, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sampled 按第一条匹配的采样规则（无匹配时用默认采样率）决定是否记录
+func (a *AccessLogger) sampled(paths []string) bool {
+	rate := a.sampleRate
+rules:
+	for _, rule := range a.sampleRules {
+		for _, p := range paths {
+			if matchPath(rule.pattern, p) {
+				rate = rule.rate
+				break rules
+			}
+		}
+	}
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// write 按配置的格式输出一行访问日志
+func (a *AccessLogger) write(r *http.Request, rec *accessRecorder, upstream *upstreamInfo, start time.Time) {
+	duration := time.Since(start)
+	clientIP := GetRequestIP(r)
+	uri := RedactURL(r.URL.RequestURI())
+
+	var line []byte
+	switch a.format {
+	case AccessLogJSON:
+		entry := map[string]interface{}{
+			"time":        start.Format(time.RFC3339Nano),
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"uri":         uri,
+			"proto":       r.Proto,
+			"status":      rec.status,
+			"bytes":       rec.bytes,
+			"duration_ms": float64(duration.Microseconds()) / 1000,
+			"client_ip":   clientIP,
+			"user_agent":  r.UserAgent(),
+			"referer":     RedactURL(r.Referer()),
+		}
+		if id := RequestID(r.Context()); id != "" {
+			entry["request_id"] = id
+		}
+		if upstream.host != "" {
+			entry["upstream_host"] = upstream.host
+			entry["upstream_status"] = upstream.status
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		line = append(data, '\n')
+	default:
+		bytesField := "-"
+		if rec.bytes > 0 {
+			bytesField = strconv.FormatInt(rec.bytes, 10)
+		}
+		s := fmt.Sprintf("%s - - [%s] %q %d %s", clientIP, start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+uri+" "+r.Proto, rec.status, bytesField)
+		if a.format == AccessLogCombined {
+			// 在标准字段后附加: 耗时（秒）、上游主机、上游状态码，无上游时为 -
+			upstreamHost, upstreamStatus := "-", "-"
+			if upstream.host != "" {
+				upstreamHost = upstream.host
+				upstreamStatus = strconv.Itoa(upstream.status)
+			}
+			s += fmt.Sprintf(" %q %q %.3f %s %s", orDash(RedactURL(r.Referer())), orDash(r.UserAgent()),
+				duration.Seconds(), upstreamHost, upstreamStatus)
+		}
+		line = []byte(s + "\n")
+	}
+
+	a.mu.Lock()
+	a.w.Write(line)
+	a.mu.Unlock()
+}
+
+// orDash 空字符串替换为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// accessRecorder 记录响应状态码和写出的字节数
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *accessRecorder) WriteHeader(code int) {
+	if rec.status == 0 && code >= 200 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *accessRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush 支持流式响应（如 SSE）
+func (rec *accessRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (rec *accessRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
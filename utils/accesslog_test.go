@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.ts", "/video/seg-001.ts", true},
+		{"*.ts", "/video/index.m3u8", false},
+		{"/api/*", "/api/search", true},
+		{"/api/*", "/api/a/b", false},
+		{"/health", "/health", true},
+		{"/health", "/healthz", false},
+		{"/proxy", "/proxy/sub", true},
+		{"/proxy/", "/proxy/sub", true},
+		{"/proxy", "/", false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNewAccessLoggerInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts AccessLogOptions
+	}{
+		{"无效格式", AccessLogOptions{Format: "xml", SampleRate: 1}},
+		{"采样率超出范围", AccessLogOptions{SampleRate: 1.5}},
+		{"采样规则缺少 =", AccessLogOptions{SampleRate: 1, SampleRules: "/proxy"}},
+		{"采样规则的采样率无效", AccessLogOptions{SampleRate: 1, SampleRules: "/proxy=abc"}},
+		{"采样规则的采样率超出范围", AccessLogOptions{SampleRate: 1, SampleRules: "/proxy=-0.1"}},
+	}
+	for _, tt := range tests {
+		if _, err := NewAccessLogger(tt.opts, &bytes.Buffer{}); err == nil {
+			t.Errorf("%s: NewAccessLogger() 应返回错误", tt.name)
+		}
+	}
+}
+
+func TestAccessLoggerFilter(t *testing.T) {
+	opts := AccessLogOptions{
+		Format:      AccessLogCommon,
+		SampleRate:  1,
+		SampleRules: "/proxy=0, *.m3u8=1, /search=0",
+		Exclude:     "*.ts, /health",
+	}
+	tests := []struct {
+		name     string
+		path     string
+		upstream string // 通过 SetUpstream 登记的上游地址
+		status   int
+		want     bool
+	}{
+		{"默认采样率为 1", "/api/sources", "", http.StatusOK, true},
+		{"排除的路径", "/health", "", http.StatusOK, false},
+		{"按扩展名排除", "/video/seg.ts", "", http.StatusOK, false},
+		{"按上游路径排除", "/proxy", "http://cdn.example.com/seg-1.ts", http.StatusOK, false},
+		{"排除规则对 5xx 同样生效", "/health", "", http.StatusServiceUnavailable, false},
+		{"采样率为 0 的路径", "/proxy", "http://cdn.example.com/video.mp4", http.StatusOK, false},
+		{"先匹配的采样规则生效", "/proxy", "http://cdn.example.com/index.m3u8", http.StatusOK, false},
+		{"按上游路径匹配采样规则", "/img", "http://cdn.example.com/index.m3u8", http.StatusOK, true},
+		{"采样率为 0 时 5xx 仍记录", "/search", "", http.StatusBadGateway, true},
+		{"采样率为 0 时 4xx 不记录", "/search", "", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			a, err := NewAccessLogger(opts, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.upstream != "" {
+					target, _ := url.Parse(tt.upstream)
+					SetUpstream(r.Context(), target, http.StatusOK)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+			if got := buf.Len() > 0; got != tt.want {
+				t.Errorf("记录日志 = %v, want %v: %q", got, tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// serveAccessLog 以指定格式记录一次 /proxy 请求并返回日志行
+func serveAccessLog(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	a, err := NewAccessLogger(AccessLogOptions{Format: format, SampleRate: 1}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target, _ := url.Parse("http://cdn.example.com/video.mp4?token=abc")
+		SetUpstream(r.Context(), target, http.StatusPartialContent)
+		w.Write([]byte("hello"))
+	}))
+	r := httptest.NewRequest("GET", "/proxy?url=http%3A%2F%2Fcdn.example.com%2Fvideo.mp4%3Ftoken%3Dabc", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", "test-agent")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if strings.Contains(buf.String(), "abc") {
+		t.Errorf("日志中包含敏感参数: %q", buf.String())
+	}
+	return buf.String()
+}
+
+func TestAccessLoggerFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   *regexp.Regexp
+	}{
+		{AccessLogCommon, regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /proxy\?url=[^ ]+ HTTP/1\.1" 200 5\n$`)},
+		{AccessLogCombined, regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /proxy\?url=[^ ]+ HTTP/1\.1" 200 5 "-" "test-agent" \d+\.\d{3} cdn\.example\.com 206\n$`)},
+		{"", regexp.MustCompile(`" 200 5 "-" "test-agent" \d+\.\d{3} cdn\.example\.com 206\n$`)},
+	}
+	for _, tt := range tests {
+		if line := serveAccessLog(t, tt.format); !tt.want.MatchString(line) {
+			t.Errorf("%q 格式日志 = %q", tt.format, line)
+		}
+	}
+}
+
+func TestAccessLoggerJSON(t *testing.T) {
+	line := serveAccessLog(t, AccessLogJSON)
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("无效的 JSON 日志 %q: %v", line, err)
+	}
+	want := map[string]interface{}{
+		"method":          "GET",
+		"path":            "/proxy",
+		"status":          float64(200),
+		"bytes":           float64(5),
+		"client_ip":       "192.0.2.1",
+		"user_agent":      "test-agent",
+		"upstream_host":   "cdn.example.com",
+		"upstream_status": float64(206),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if uri, _ := entry["uri"].(string); !strings.Contains(uri, "REDACTED") {
+		t.Errorf("uri = %q, 应隐藏敏感参数", uri)
+	}
+}
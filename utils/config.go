@@ -2,17 +2,26 @@ package utils
 
 import (
 	"fmt"
-	"log"
 
 	"gopkg.in/ini.v1"
 )
 
+// configLog 配置加载日志
+var configLog = Logger("config")
+
 // Config 配置结构体
 type Config struct {
 	Server struct {
-		Port    string `ini:"port"`
-		Host    string `ini:"host"`
-		Timeout int    `ini:"timeout"`
+		Port                     string `ini:"port"`
+		Host                     string `ini:"host"`
+		Timeout                  int    `ini:"timeout"`
+		ReadHeaderTimeoutSeconds int    `ini:"read_header_timeout_seconds"`
+		ReadTimeoutSeconds       int    `ini:"read_timeout_seconds"`
+		WriteTimeoutSeconds      int    `ini:"write_timeout_seconds"`
+		IdleTimeoutSeconds       int    `ini:"idle_timeout_seconds"`
+		ShutdownTimeoutSeconds   int    `ini:"shutdown_timeout_seconds"`
+		PortFallback             string `ini:"port_fallback"`
+		UnixSocket               string `ini:"unix_socket"`
 	} `ini:"server"`
 	Proxy struct {
 		UserAgent          string `ini:"user_agent"`
@@ -27,12 +36,40 @@ type Config struct {
 		LogLevel      string `ini:"log_level"`
 		ConsoleOutput bool   `ini:"console_output"`
 		FileOutput    bool   `ini:"file_output"`
+		// 日志格式: text 或 json
+		Format string `ini:"format"`
+		// 按组件设置日志级别，如 "proxy=warn, douban=debug"
+		ComponentLevels string `ini:"component_levels"`
+		// 日志中隐藏值的查询参数名，逗号分隔，留空使用内置列表
+		RedactParams string `ini:"redact_params"`
+		// 日志文件超过该大小（MB）时轮转，0 表示不按大小轮转
+		MaxSizeMB int `ini:"max_size_mb"`
+		// 按时间轮转的周期（小时，按本地时间对齐），0 表示不按时间轮转
+		RotateIntervalHours int `ini:"rotate_interval_hours"`
+		// 保留的轮转文件数量，0 表示全部保留
+		MaxBackups int `ini:"max_backups"`
+		// 轮转后的文件使用 gzip 压缩
+		Compress bool `ini:"compress"`
 	} `ini:"logging"`
+	AccessLog struct {
+		Enabled bool `ini:"enabled"`
+		// 格式: common、combined 或 json
+		Format string `ini:"format"`
+		// 单独的访问日志文件，留空时与程序日志输出到同一位置
+		File string `ini:"file"`
+		// 默认采样率（0~1）
+		SampleRate float64 `ini:"sample_rate"`
+		// 按路径设置采样率，如 "/proxy=0.1, *.m3u8=1"
+		SampleRules string `ini:"sample_rules"`
+		// 不记录的路径，如 "*.ts, /health"
+		Exclude string `ini:"exclude"`
+	} `ini:"access_log"`
 	Security struct {
 		CorsEnabled    bool   `ini:"cors_enabled"`
 		CorsOrigin     string `ini:"cors_origin"`
 		AllowedMethods string `ini:"allowed_methods"`
 		AllowedHeaders string `ini:"allowed_headers"`
+		TrustedProxies string `ini:"trusted_proxies"`
 	} `ini:"security"`
 	Features struct {
 		HealthCheck  bool `ini:"health_check"`
@@ -43,7 +80,88 @@ type Config struct {
 	Filter struct {
 		AdminPassword      string `ini:"admin_password"`
 		DefaultAdultFilter bool   `ini:"default_adult_filter"`
+		BlockedTypes       string `ini:"blocked_types"`
+		BlockedKeywords    string `ini:"blocked_keywords"`
 	} `ini:"filter"`
+	Auth struct {
+		PasswordHash     string `ini:"password_hash"`
+		PasswordHashFile string `ini:"password_hash_file"`
+		SessionTTLHours  int    `ini:"session_ttl_hours"`
+		CookieSecure     bool   `ini:"cookie_secure"`
+	} `ini:"auth"`
+	Scorpio struct {
+		File                 string `ini:"file"`
+		CheckEnabled         bool   `ini:"check_enabled"`
+		CheckIntervalMinutes int    `ini:"check_interval_minutes"`
+		CheckConcurrency     int    `ini:"check_concurrency"`
+		CheckJitterSeconds   int    `ini:"check_jitter_seconds"`
+		SampleKeyword        string `ini:"sample_keyword"`
+		ProbePlayURL         bool   `ini:"probe_play_url"`
+		HistoryFile          string `ini:"history_file"`
+		HistorySize          int    `ini:"history_size"`
+		AutoPromoteTop       int    `ini:"auto_promote_top"`
+		AutoPromoteReplace   bool   `ini:"auto_promote_replace"`
+	} `ini:"scorpio"`
+	Sources struct {
+		LocalFile string `ini:"local_file"`
+	} `ini:"sources"`
+	Breaker struct {
+		FailureThreshold int `ini:"failure_threshold"`
+		CooldownSeconds  int `ini:"cooldown_seconds"`
+	} `ini:"breaker"`
+	Cache struct {
+		Enabled                     bool   `ini:"enabled"`
+		MaxEntries                  int    `ini:"max_entries"`
+		Dir                         string `ini:"dir"`
+		SearchTTLSeconds            int    `ini:"search_ttl_seconds"`
+		LatestTTLSeconds            int    `ini:"latest_ttl_seconds"`
+		MatchTTLSeconds             int    `ini:"match_ttl_seconds"`
+		StaleWhileRevalidateSeconds int    `ini:"stale_while_revalidate_seconds"`
+		StaleIfErrorSeconds         int    `ini:"stale_if_error_seconds"`
+		DiskMaxAgeHours             int    `ini:"disk_max_age_hours"`
+		DiskMaxMB                   int    `ini:"disk_max_mb"`
+	} `ini:"cache"`
+	Douban struct {
+		CacheEnabled                bool `ini:"cache_enabled"`
+		TagsTTLSeconds              int  `ini:"tags_ttl_seconds"`
+		SubjectsTTLSeconds          int  `ini:"subjects_ttl_seconds"`
+		DetailTTLSeconds            int  `ini:"detail_ttl_seconds"`
+		SuggestTTLSeconds           int  `ini:"suggest_ttl_seconds"`
+		ChartTTLSeconds             int  `ini:"chart_ttl_seconds"`
+		StaleWhileRevalidateSeconds int  `ini:"stale_while_revalidate_seconds"`
+		StaleIfErrorSeconds         int  `ini:"stale_if_error_seconds"`
+		BackoffSeconds              int  `ini:"backoff_seconds"`
+		MaxBackoffSeconds           int  `ini:"max_backoff_seconds"`
+		ProxyCovers                 bool `ini:"proxy_covers"`
+	} `ini:"douban"`
+	TLS struct {
+		Enabled               bool   `ini:"enabled"`
+		CertFile              string `ini:"cert_file"`
+		KeyFile               string `ini:"key_file"`
+		SelfSigned            bool   `ini:"self_signed"`
+		SelfSignedDir         string `ini:"self_signed_dir"`
+		ReloadIntervalSeconds int    `ini:"reload_interval_seconds"`
+		RedirectPort          string `ini:"redirect_port"`
+		HTTP2                 bool   `ini:"http2"`
+	} `ini:"tls"`
+	Image struct {
+		Enabled             bool   `ini:"enabled"`
+		CacheDir            string `ini:"cache_dir"`
+		CacheTTLHours       int    `ini:"cache_ttl_hours"`
+		CacheMaxMB          int    `ini:"cache_max_mb"`
+		ClientMaxAgeSeconds int    `ini:"client_max_age_seconds"`
+		MaxWidth            int    `ini:"max_width"`
+		JPEGQuality         int    `ini:"jpeg_quality"`
+		MaxSourceMB         int    `ini:"max_source_mb"`
+		Referers            string `ini:"referers"`
+	} `ini:"image"`
+	Bandwidth struct {
+		Enabled         bool   `ini:"enabled"`
+		GlobalLimitKBps int    `ini:"global_limit_kbps"`
+		ClientLimitKBps int    `ini:"client_limit_kbps"`
+		BurstKB         int    `ini:"burst_kb"`
+		Exempt          string `ini:"exempt"`
+	} `ini:"bandwidth"`
 }
 
 // LoadConfigFromData 从配置数据加载配置
@@ -59,6 +177,6 @@ func LoadConfigFromData(configData []byte) (*Config, error) {
 		return nil, fmt.Errorf("映射配置失败: %v", err)
 	}
 
-	log.Printf("✅ 配置文件加载成功")
+	configLog.Info("✅ 配置文件加载成功")
 	return &config, nil
 }
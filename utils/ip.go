@@ -1,41 +1,202 @@
 package utils
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 )
 
-// GetRequestIP 获取请求的真实IP地址
+var (
+	trustedMu      sync.RWMutex
+	trustedProxies []*net.IPNet
+)
+
+// SetTrustedProxies 设置受信任的代理列表（逗号分隔的 IP/CIDR）
+// 只有直连对端在该列表中时，才会信任转发头部中的客户端IP
+func SetTrustedProxies(list string) error {
+	nets, err := ParseCIDRList(list)
+	if err != nil {
+		return err
+	}
+	trustedMu.Lock()
+	trustedProxies = nets
+	trustedMu.Unlock()
+	return nil
+}
+
+// isTrustedProxy 判断IP是否为受信任的代理
+func isTrustedProxy(ip net.IP) bool {
+	trustedMu.RLock()
+	defer trustedMu.RUnlock()
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRequestIP 获取请求的真实IP地址，无法确定时返回 RemoteAddr 或 unknown
 func GetRequestIP(r *http.Request) string {
-	// 尝试从各种头部获取真实IP
+	if ip := ResolveRequestIP(r); ip != nil {
+		return ip.String()
+	}
+	if r.RemoteAddr != "" {
+		return r.RemoteAddr
+	}
+	return "unknown"
+}
+
+// ResolveRequestIP 获取请求的真实IP地址，无法确定时返回 nil
+// 通过 Unix socket 连接的对端只能是本机进程（通常是反向代理），视为受信任的代理；
+// 反向代理未添加 X-Forwarded-For/Forwarded 等头部时无法确定客户端IP
+func ResolveRequestIP(r *http.Request) net.IP {
+	peer := parseRemoteAddr(r.RemoteAddr)
+	if peer == nil {
+		if isUnixSocketRequest(r) {
+			return forwardedIP(r, nil)
+		}
+		return nil
+	}
+
+	// 直连对端不受信任时，忽略所有转发头部
+	if !isTrustedProxy(peer) {
+		return peer
+	}
+	return forwardedIP(r, peer)
+}
+
+// isUnixSocketRequest 判断请求是否来自 Unix socket 监听
+func isUnixSocketRequest(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr != nil && (addr.Network() == "unix" || addr.Network() == "unixpacket")
+}
+
+// forwardedIP 从受信任代理添加的转发头部中获取客户端IP，没有可用的头部时返回 peer
+func forwardedIP(r *http.Request, peer net.IP) net.IP {
+	// 标准 Forwarded 头部（RFC 7239）
+	if hops := parseForwardedFor(r.Header.Values("Forwarded")); len(hops) > 0 {
+		return walkForwardedChain(hops, peer)
+	}
+
+	// X-Forwarded-For，从右向左跳过受信任的代理
+	if hops := splitHeaderList(r.Header.Values("X-Forwarded-For")); len(hops) > 0 {
+		return walkForwardedChain(hops, peer)
+	}
+
+	// 单值头部
 	headers := []string{
-		"X-Forwarded-For",
 		"X-Real-IP",
-		"X-Client-IP",
 		"CF-Connecting-IP", // Cloudflare
 		"True-Client-IP",   // Akamai
+		"X-Client-IP",
 	}
-
 	for _, header := range headers {
-		if ip := r.Header.Get(header); ip != "" {
-			// 如果IP包含多个值（如X-Forwarded-For），取第一个
-			if strings.Contains(ip, ",") {
-				ip = strings.TrimSpace(strings.Split(ip, ",")[0])
+		if ip := parseHostIP(r.Header.Get(header)); ip != nil {
+			return ip
+		}
+	}
+	return peer
+}
+
+// parseRemoteAddr 解析 RemoteAddr（IP:PORT、[IPv6]:PORT 或不带端口的IP）
+func parseRemoteAddr(addr string) net.IP {
+	if addr == "" {
+		return nil
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
+
+// parseHostIP 解析可能带端口或方括号的IP地址
+func parseHostIP(s string) net.IP {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if s == "" {
+		return nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+// splitHeaderList 将多个逗号分隔的头部值展开为列表
+func splitHeaderList(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
 			}
-			if ip != "" && ip != "unknown" {
-				return ip
+		}
+	}
+	return items
+}
+
+// parseForwardedFor 提取 Forwarded 头部中所有 for= 的值
+func parseForwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitHeaderList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				hops = append(hops, strings.TrimSpace(kv[1]))
 			}
 		}
 	}
+	return hops
+}
 
-	// 如果头部都没有，使用RemoteAddr
-	if r.RemoteAddr != "" {
-		// RemoteAddr格式通常是 "IP:PORT"，需要去掉端口
-		if strings.Contains(r.RemoteAddr, ":") {
-			return strings.Split(r.RemoteAddr, ":")[0]
+// walkForwardedChain 从右向左遍历转发链，返回第一个不受信任的地址
+// 遇到无法解析的地址（如 unknown 或混淆标识）时停止，返回其右侧最后一个有效地址
+func walkForwardedChain(hops []string, peer net.IP) net.IP {
+	result := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHostIP(hops[i])
+		if ip == nil {
+			break
+		}
+		result = ip
+		if !isTrustedProxy(ip) {
+			break
 		}
-		return r.RemoteAddr
 	}
+	return result
+}
 
-	return "unknown"
+// ParseCIDRList 解析逗号分隔的 IP/CIDR 列表，单个 IP 视为 /32 或 /128
+func ParseCIDRList(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的IP地址: %s", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的CIDR: %s", item)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
 }
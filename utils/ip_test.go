@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetRequestIP(t *testing.T) {
+	if err := SetTrustedProxies("10.0.0.0/8, 127.0.0.1, ::1"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies("")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"直连不信任时忽略转发头", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.5"},
+		{"受信任代理的 X-Forwarded-For", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"跳过链中受信任的代理", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 10.1.2.3"}, "198.51.100.1"},
+		{"伪造的最左侧地址不被采用", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"Forwarded 优先于 X-Forwarded-For", "127.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db8::1]:80"`, "X-Forwarded-For": "198.51.100.1"}, "2001:db8::1"},
+		{"无法解析的地址停止遍历", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "unknown, 10.1.2.3"}, "10.1.2.3"},
+		{"单值头部", "[::1]:1234", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"受信任代理没有转发头", "10.0.0.1:80", nil, "10.0.0.1"},
+		{"没有端口的 RemoteAddr", "203.0.113.5", nil, "203.0.113.5"},
+		{"空 RemoteAddr", "", nil, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := GetRequestIP(r); got != tt.want {
+				t.Errorf("GetRequestIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRequestIPUnixSocket(t *testing.T) {
+	unixAddr := &net.UnixAddr{Name: "/run/vastvideo.sock", Net: "unix"}
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+		known   bool // ResolveRequestIP 能否确定客户端IP
+	}{
+		{"X-Forwarded-For", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1", true},
+		{"Forwarded", map[string]string{"Forwarded": "for=198.51.100.2;proto=https"}, "198.51.100.2", true},
+		{"没有转发头", nil, "@", false},
+		{"转发头无法解析", map[string]string{"X-Forwarded-For": "unknown"}, "@", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "@"
+			r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, unixAddr))
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := GetRequestIP(r); got != tt.want {
+				t.Errorf("GetRequestIP() = %q, want %q", got, tt.want)
+			}
+			if got := ResolveRequestIP(r) != nil; got != tt.known {
+				t.Errorf("ResolveRequestIP() != nil = %v, want %v", got, tt.known)
+			}
+		})
+	}
+}
+
+func TestWalkForwardedChain(t *testing.T) {
+	if err := SetTrustedProxies("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies("")
+
+	peer := net.ParseIP("10.0.0.1")
+	tests := []struct {
+		hops []string
+		want string
+	}{
+		{[]string{"198.51.100.1"}, "198.51.100.1"},
+		{[]string{"198.51.100.1", "10.0.0.2", "10.0.0.3"}, "198.51.100.1"},
+		{[]string{"10.0.0.2"}, "10.0.0.2"},
+		{[]string{"_hidden", "10.0.0.2"}, "10.0.0.2"},
+		{[]string{"198.51.100.1:8080"}, "198.51.100.1"},
+		{[]string{"garbage"}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := walkForwardedChain(tt.hops, peer).String(); got != tt.want {
+			t.Errorf("walkForwardedChain(%v) = %q, want %q", tt.hops, got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDRList(t *testing.T) {
+	nets, err := ParseCIDRList("127.0.0.1, 10.0.0.0/8, ::1,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("len = %d, want 3", len(nets))
+	}
+	if _, err := ParseCIDRList("not-an-ip"); err == nil {
+		t.Error("无效的地址应返回错误")
+	}
+}
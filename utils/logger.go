@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LogOptions 日志配置，对应 [logging]
+type LogOptions struct {
+	Level           string // debug、info、warn、error
+	Format          string // text 或 json
+	ComponentLevels string // 按组件设置级别，如 "proxy=warn, douban=debug"
+	RedactParams    string // 日志中隐藏的查询参数名，逗号分隔
+}
+
+// 默认隐藏的查询参数名
+const defaultRedactParams = "password,passwd,pwd,token,access_token,refresh_token,key,api_key,apikey,secret,sign,signature,auth,session,sid"
+
+// 日志中按属性名整体隐藏的字段
+var sensitiveAttrKeys = map[string]bool{
+	"password":         true,
+	"cookie":           true,
+	"set-cookie":       true,
+	"authorization":    true,
+	"token":            true,
+	"x-admin-password": true,
+}
+
+// 日志中按 URL 处理的字段，其中的敏感查询参数会被隐藏
+var urlAttrKeys = map[string]bool{
+	"url":      true,
+	"upstream": true,
+	"referer":  true,
+	"query":    true,
+	"target":   true,
+}
+
+// redactedValue 替换敏感值的占位符
+const redactedValue = "REDACTED"
+
+// logState 当前日志配置，SetupLogging 可在运行中替换
+type logState struct {
+	handler    slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+	redact     map[string]bool
+}
+
+var currentLog atomic.Pointer[logState]
+
+func init() {
+	state := &logState{level: slog.LevelInfo, redact: parseRedactParams(defaultRedactParams)}
+	state.handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: state.replaceAttr})
+	currentLog.Store(state)
+}
+
+// SetupLogging 按配置设置日志输出格式、级别和脱敏规则，同时接管标准库 log 的输出
+func SetupLogging(opts LogOptions, w io.Writer) error {
+	level, err := ParseLogLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	state := &logState{level: level, components: make(map[string]slog.Level)}
+	for _, rule := range strings.Split(opts.ComponentLevels, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(rule, "=")
+		if !ok {
+			return fmt.Errorf("无效的组件日志级别: %s", rule)
+		}
+		l, err := ParseLogLevel(value)
+		if err != nil {
+			return err
+		}
+		state.components[strings.TrimSpace(name)] = l
+	}
+	redactParams := opts.RedactParams
+	if strings.TrimSpace(redactParams) == "" {
+		redactParams = defaultRedactParams
+	}
+	state.redact = parseRedactParams(redactParams)
+
+	// 级别由 componentHandler 判断，底层处理器放行所有级别
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: state.replaceAttr}
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "", "text":
+		state.handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		state.handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return fmt.Errorf("无效的日志格式: %s，可选 text 或 json", opts.Format)
+	}
+	currentLog.Store(state)
+	slog.SetDefault(Logger("std"))
+	return nil
+}
+
+// ParseLogLevel 解析日志级别名称
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("无效的日志级别: %s", name)
+}
+
+// parseRedactParams 解析需要隐藏的参数名列表
+func parseRedactParams(list string) map[string]bool {
+	params := make(map[string]bool)
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			params[p] = true
+		}
+	}
+	return params
+}
+
+// replaceAttr 隐藏敏感字段，URL 类字段只隐藏其中的敏感参数
+func (s *logState) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if sensitiveAttrKeys[key] {
+		return slog.String(a.Key, redactedValue)
+	}
+	if urlAttrKeys[key] && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, s.redactURL(a.Value.String()))
+	}
+	return a
+}
+
+// RedactURL 隐藏 URL 中的敏感查询参数和用户密码，用于记录日志
+func RedactURL(raw string) string {
+	return currentLog.Load().redactURL(raw)
+}
+
+func (s *logState) redactURL(raw string) string {
+	if !strings.ContainsAny(raw, "?=@") {
+		return raw
+	}
+	// 纯查询字符串（如 RawQuery，第一个 = 之前不含 :/?）直接按查询参数处理
+	if name, _, ok := strings.Cut(raw, "="); ok && !strings.ContainsAny(name, ":/?") {
+		query, err := url.ParseQuery(raw)
+		if err != nil {
+			return raw
+		}
+		s.redactQuery(query)
+		return query.Encode()
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+		}
+	}
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return raw
+	}
+	s.redactQuery(query)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactQuery 隐藏查询参数中的敏感值
+func (s *logState) redactQuery(query url.Values) {
+	for name, values := range query {
+		for i, v := range values {
+			switch {
+			case s.redact[strings.ToLower(name)]:
+				values[i] = redactedValue
+			case strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://"):
+				// 嵌套的 URL 参数（如 /proxy?url=...）同样处理
+				values[i] = s.redactURL(v)
+			}
+		}
+	}
+}
+
+// RedactHeaders 返回隐藏了 Cookie、Authorization 等敏感值的请求头或响应头副本，用于记录日志
+func RedactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	for name := range redacted {
+		if sensitiveAttrKeys[strings.ToLower(name)] {
+			redacted[name] = []string{redactedValue}
+		}
+	}
+	return redacted
+}
+
+// componentHandler 按组件判断日志级别，并附加请求上下文中的字段
+// 底层处理器来自当前日志配置，SetupLogging 之前创建的 Logger 同样生效
+type componentHandler struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler // WithAttrs/WithGroup 调用，处理时按顺序应用
+}
+
+// Logger 返回指定组件的日志记录器，组件名用于 component_levels 配置
+func Logger(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component}).With("component", component)
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	state := currentLog.Load()
+	minLevel := state.level
+	if l, ok := state.components[h.component]; ok {
+		minLevel = l
+	}
+	return level >= minLevel
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := currentLog.Load().handler
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok && len(attrs) > 0 {
+		handler = handler.WithAttrs(attrs)
+	}
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *componentHandler) with(op func(slog.Handler) slog.Handler) *componentHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &componentHandler{component: h.component, ops: append(ops, op)}
+}
+
+// logAttrsKey 请求上下文中日志字段的键
+type logAttrsKey struct{}
+
+// WithLogAttrs 返回附加了日志字段的上下文，使用该上下文记录的日志都会带上这些字段
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, logAttrsKey{}, merged)
+}
+
+// RequestIDHeader 请求 ID 响应头，客户端或上游代理传入时沿用
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey 请求上下文中请求 ID 的键
+type requestIDKey struct{}
+
+// RequestID 返回请求上下文中的请求 ID
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestLogging 为每个请求分配请求 ID，并在请求上下文中附加 request_id、client_ip 日志字段
+func WithRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = WithLogAttrs(ctx, slog.String("request_id", id), slog.String("client_ip", GetRequestIP(r)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID 只沿用长度合理且仅含字母、数字、-、_ 的请求 ID
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDSeq 随机数不可用时生成请求 ID 的序号
+var requestIDSeq atomic.Uint64
+
+// newRequestID 生成随机请求 ID，读取随机数失败时改用时间戳与序号，保证仍然唯一
+func newRequestID() string {
+	return requestIDFrom(rand.Reader)
+}
+
+// requestIDFrom 从 random 读取 8 字节生成请求 ID
+func requestIDFrom(random io.Reader) string {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return fmt.Sprintf("t%x-%x", time.Now().UnixNano(), requestIDSeq.Add(1))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions 日志文件轮转配置，对应 [logging]
+type RotateOptions struct {
+	Path       string
+	MaxSizeMB  int           // 文件超过该大小时轮转，0 表示不按大小轮转
+	Interval   time.Duration // 按时间轮转的周期（按本地时间对齐，如每天零点），0 表示不按时间轮转
+	MaxBackups int           // 保留的轮转文件数量，0 表示全部保留
+	Compress   bool          // 轮转后的文件使用 gzip 压缩
+}
+
+// 轮转文件名中的时间格式: vastproxy-go-2006-01-02T15-04-05.000.log
+const rotateTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile 支持按大小、按时间轮转的日志文件，可安全地并发写入
+// 轮转后的文件名为 <名称>-<时间>.<扩展名>，压缩和清理旧文件在后台进行
+type RotatingFile struct {
+	opts RotateOptions
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+
+	millMu sync.Mutex // 串行执行压缩与清理
+}
+
+// NewRotatingFile 打开（追加写入）日志文件
+func NewRotatingFile(opts RotateOptions) (*RotatingFile, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("未配置日志文件路径")
+	}
+	r := &RotatingFile{opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open 打开日志文件并计算下次按时间轮转的时刻，调用方需持有 mu（初始化时除外）
+func (r *RotatingFile) open() error {
+	if dir := filepath.Dir(r.opts.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建日志目录失败: %v", err)
+		}
+	}
+	f, err := os.OpenFile(r.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	if r.opts.Interval > 0 {
+		r.nextRotate = nextBoundary(time.Now(), r.opts.Interval)
+	}
+	return nil
+}
+
+// nextBoundary 返回 t 之后按本地时间对齐的下一个轮转时刻
+func nextBoundary(t time.Time, interval time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(interval).Add(interval).Add(-shift)
+}
+
+// Write 写入日志，写入前检查是否需要轮转
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			// 轮转失败时继续写入当前文件，避免丢失日志；此处不能再写日志，直接输出到标准错误
+			fmt.Fprintf(os.Stderr, "⚠️ 日志文件轮转失败: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// shouldRotate 判断写入 n 字节前是否需要轮转
+func (r *RotatingFile) shouldRotate(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSizeMB > 0 && r.size+n > int64(r.opts.MaxSizeMB)*1024*1024 {
+		return true
+	}
+	return r.opts.Interval > 0 && !time.Now().Before(r.nextRotate)
+}
+
+// Rotate 立即轮转日志文件
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// rotate 将当前文件改名为带时间的备份文件并打开新文件，调用方需持有 mu
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	ext := filepath.Ext(r.opts.Path)
+	backup := strings.TrimSuffix(r.opts.Path, ext) + "-" + time.Now().Format(rotateTimeFormat) + ext
+	renameErr := os.Rename(r.opts.Path, backup)
+	if err := r.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	go r.mill(backup)
+	return nil
+}
+
+// Reopen 关闭并重新打开日志文件，用于配合外部 logrotate（文件已被移走后写入新文件）
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	return r.open()
+}
+
+// Close 关闭日志文件
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// mill 压缩刚轮转的文件并清理超出保留数量的旧文件
+func (r *RotatingFile) mill(backup string) {
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+	if r.opts.Compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ 压缩日志文件 %s 失败: %v\n", backup, err)
+		}
+	}
+	if r.opts.MaxBackups > 0 {
+		backups := r.backups()
+		for _, old := range backups[min(len(backups), r.opts.MaxBackups):] {
+			if err := os.Remove(old); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️ 删除旧日志文件 %s 失败: %v\n", old, err)
+			}
+		}
+	}
+}
+
+// backups 返回已轮转的文件（含压缩文件），按时间从新到旧排列
+func (r *RotatingFile) backups() []string {
+	ext := filepath.Ext(r.opts.Path)
+	prefix := filepath.Base(strings.TrimSuffix(r.opts.Path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.opts.Path))
+	if err != nil {
+		return nil
+	}
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var found []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		at, err := time.ParseInLocation(rotateTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		found = append(found, backup{path: filepath.Join(filepath.Dir(r.opts.Path), name), at: at})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].at.After(found[j].at) })
+	paths := make([]string, len(found))
+	for i, b := range found {
+		paths[i] = b.path
+	}
+	return paths
+}
+
+// gzipFile 将文件压缩为 .gz 并删除原文件
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
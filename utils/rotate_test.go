@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor 等待后台的压缩与清理完成
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	r, err := NewRotatingFile(RotateOptions{Path: path, MaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		// 轮转文件名精确到毫秒
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	if n := len(r.backups()); n != 2 {
+		t.Errorf("轮转文件数 = %d, want 2", n)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(chunk)) {
+		t.Errorf("当前文件大小 = %d, want %d", info.Size(), len(chunk))
+	}
+}
+
+func TestRotatingFileRetention(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackups int
+		compress   bool
+		rotations  int
+		wantCount  int
+	}{
+		{"全部保留", 0, false, 3, 3},
+		{"保留最近两个", 2, false, 4, 2},
+		{"压缩并保留最近两个", 2, true, 4, 2},
+		{"压缩全部保留", 0, true, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			r, err := NewRotatingFile(RotateOptions{Path: path, MaxBackups: tt.maxBackups, Compress: tt.compress})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			for i := 0; i < tt.rotations; i++ {
+				if _, err := r.Write([]byte("line\n")); err != nil {
+					t.Fatal(err)
+				}
+				if err := r.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			waitFor(t, func() bool {
+				backups := r.backups()
+				if len(backups) != tt.wantCount {
+					return false
+				}
+				for _, b := range backups {
+					if strings.HasSuffix(b, ".gz") != tt.compress {
+						return false
+					}
+				}
+				return true
+			})
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("轮转后应创建新的日志文件: %v", err)
+			}
+		})
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := NewRotatingFile(RotateOptions{Path: path, Interval: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Write([]byte("before\n"))
+	if n := len(r.backups()); n != 0 {
+		t.Fatalf("未到轮转时刻时轮转文件数 = %d, want 0", n)
+	}
+
+	// 模拟到达轮转时刻
+	r.mu.Lock()
+	r.nextRotate = time.Now().Add(-time.Second)
+	r.mu.Unlock()
+	r.Write([]byte("after\n"))
+
+	if n := len(r.backups()); n != 1 {
+		t.Errorf("轮转文件数 = %d, want 1", n)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "after\n" {
+		t.Errorf("当前文件内容 = %q, want %q", data, "after\n")
+	}
+	if !r.nextRotate.After(time.Now()) {
+		t.Error("轮转后应更新下次轮转时刻")
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	r, err := NewRotatingFile(RotateOptions{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("old\n"))
+
+	// 外部 logrotate 移走文件后重新打开
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("new\n"))
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("重新打开后文件内容 = %q, want %q", data, "new\n")
+	}
+
+	r.Close()
+	if _, err := r.Write([]byte("closed\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("关闭后写入 err = %v, want os.ErrClosed", err)
+	}
+}
+
+func TestNextBoundary(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		t        time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{time.Date(2024, 1, 1, 15, 30, 0, 0, loc), 24 * time.Hour, time.Date(2024, 1, 2, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, loc), 24 * time.Hour, time.Date(2024, 1, 2, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 1, 1, 15, 30, 0, 0, loc), time.Hour, time.Date(2024, 1, 1, 16, 0, 0, 0, loc)},
+		{time.Date(2024, 1, 1, 23, 59, 59, 0, loc), time.Hour, time.Date(2024, 1, 2, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 1, 1, 15, 30, 0, 0, time.UTC), 24 * time.Hour, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := nextBoundary(tt.t, tt.interval); !got.Equal(tt.want) {
+			t.Errorf("nextBoundary(%v, %v) = %v, want %v", tt.t, tt.interval, got, tt.want)
+		}
+	}
+}